@@ -1,21 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/mailer"
 	"github.com/mohammadhprp/passport/internal/models"
 	"github.com/mohammadhprp/passport/internal/routers"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take to finish
+// once a stop signal arrives.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	loadDotEnv()
 
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
 
 	db, err := config.NewPostgresConnection(cfg)
 	if err != nil {
@@ -26,11 +40,41 @@ func main() {
 		log.Fatalf("failed to run migrations: %v", err)
 	}
 
-	router := routers.NewRouter(db)
+	redisClient, err := config.NewRedisClient(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to redis: %v", err)
+	}
+
+	mailTemplates, err := mailer.LoadTemplates(cfg.MailTemplatesDir)
+	if err != nil {
+		log.Fatalf("failed to load email templates: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	router, workers := routers.NewRouter(cfg, db, redisClient, mailTemplates)
+	for _, worker := range workers {
+		go worker.Run(ctx)
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", cfg.AppPort),
+		Handler: router,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("graceful shutdown failed: %v", err)
+		}
+	}()
 
-	address := fmt.Sprintf(":%s", cfg.AppPort)
-	log.Printf("listening on %s", address)
-	if err := router.Run(address); err != nil {
+	log.Printf("listening on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server stopped: %v", err)
 	}
 }
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -21,7 +23,15 @@ type Config struct {
 	RedisPort     string
 	RedisPassword string
 	RedisDB       int
-	SSO           SSOConfig
+	SMTPHost      string
+	SMTPPort      string
+	SMTPUsername  string
+	SMTPPassword  string
+	MailFrom      string
+	// MailTemplatesDir optionally overrides the embedded email templates
+	// file by file.
+	MailTemplatesDir string
+	SSO              SSOConfig
 }
 
 type CookieConfig struct {
@@ -41,34 +51,67 @@ type TokenTTLConfig struct {
 	IDToken           time.Duration
 }
 
+// LockoutConfig governs throttling of failed password logins. Delays start
+// after the free attempts and double with each further failure.
+type LockoutConfig struct {
+	FreeAttempts   int
+	IPFreeAttempts int
+	BackoffBase    time.Duration
+	BackoffMax     time.Duration
+	FailureWindow  time.Duration
+	Threshold      int
+	Duration       time.Duration
+}
+
 type SSOConfig struct {
 	IssuerURL              string
 	DefaultScopes          []string
 	PKCERequired           bool
+	SigningAlgorithm       string
 	SessionTTL             time.Duration
+	SessionIdleTimeout     time.Duration
 	LoginStateTTL          time.Duration
+	EmailVerificationTTL   time.Duration
+	EmailResendInterval    time.Duration
+	PasswordResetTTL       time.Duration
+	EmailLoginTTL          time.Duration
 	DeviceCodeTTL          time.Duration
 	DeviceCodePollInterval time.Duration
-	Cookie                 CookieConfig
-	Tokens                 TokenTTLConfig
+	MFAChallengeTTL        time.Duration
+	// MFAEncryptionKey is a base64-encoded 32 byte key used to encrypt
+	// TOTP secrets at rest.
+	MFAEncryptionKey string
+	MFAIssuer        string
+	// ScopeMinimumACR maps sensitive scopes to the weakest ACR that may be
+	// granted them, whatever acr_values the client sends.
+	ScopeMinimumACR map[string]string
+	Cookie          CookieConfig
+	Tokens          TokenTTLConfig
+	Lockout         LockoutConfig
 }
 
 // Load reads environment variables into Config. It expects godotenv to have been
 // executed by the caller when needed (e.g. in development).
 func Load() Config {
 	cfg := Config{
-		AppPort:       getEnv("APP_PORT", "3000"),
-		DBHost:        getEnv("DB_HOST", "localhost"),
-		DBPort:        getEnv("DB_PORT", "5432"),
-		DBUser:        getEnv("DB_USER", "postgres"),
-		DBPass:        getEnv("DB_PASSWORD", "postgres"),
-		DBName:        getEnv("DB_NAME", "passport"),
-		DBSSLMode:     getEnv("DB_SSL_MODE", "disable"),
-		DBTimeZone:    getEnv("DB_TIMEZONE", "UTC"),
-		RedisHost:     getEnv("REDIS_HOST", "localhost"),
-		RedisPort:     getEnv("REDIS_PORT", "6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getEnvAsInt("REDIS_DB", 0),
+		AppPort:          getEnv("APP_PORT", "3000"),
+		DBHost:           getEnv("DB_HOST", "localhost"),
+		DBPort:           getEnv("DB_PORT", "5432"),
+		DBUser:           getEnv("DB_USER", "postgres"),
+		DBPass:           getEnv("DB_PASSWORD", "postgres"),
+		DBName:           getEnv("DB_NAME", "passport"),
+		DBSSLMode:        getEnv("DB_SSL_MODE", "disable"),
+		DBTimeZone:       getEnv("DB_TIMEZONE", "UTC"),
+		RedisHost:        getEnv("REDIS_HOST", "localhost"),
+		RedisPort:        getEnv("REDIS_PORT", "6379"),
+		RedisPassword:    getEnv("REDIS_PASSWORD", ""),
+		RedisDB:          getEnvAsInt("REDIS_DB", 0),
+		SMTPHost:         getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:         getEnv("SMTP_PORT", "1025"),
+		SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		MailFrom:         getEnv("MAIL_FROM", "Passport <no-reply@passport.local>"),
+		MailTemplatesDir: getEnv("MAIL_TEMPLATES_DIR", ""),
 	}
 
 	cfg.SSO = loadSSOConfig()
@@ -76,6 +119,19 @@ func Load() Config {
 	return cfg
 }
 
+// supportedSigningAlgorithms are the SIGNING_ALGORITHM values the key manager
+// can generate keys for.
+var supportedSigningAlgorithms = []string{"RS256", "ES256"}
+
+// Validate reports settings that would otherwise only fail once the server
+// handles its first request.
+func (c Config) Validate() error {
+	if !slices.Contains(supportedSigningAlgorithms, c.SSO.SigningAlgorithm) {
+		return fmt.Errorf("SIGNING_ALGORITHM %q is not supported, use one of %v", c.SSO.SigningAlgorithm, supportedSigningAlgorithms)
+	}
+	return nil
+}
+
 func loadSSOConfig() SSOConfig {
 	cookie := CookieConfig{
 		Name:     getEnv("SESSION_COOKIE_NAME", "passport_session"),
@@ -94,16 +150,37 @@ func loadSSOConfig() SSOConfig {
 		IDToken:           getEnvAsDuration("ID_TOKEN_TTL", 5*time.Minute),
 	}
 
+	lockout := LockoutConfig{
+		FreeAttempts:   getEnvAsInt("LOGIN_FREE_ATTEMPTS", 3),
+		IPFreeAttempts: getEnvAsInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+		BackoffBase:    getEnvAsDuration("LOGIN_BACKOFF_BASE", time.Second),
+		BackoffMax:     getEnvAsDuration("LOGIN_BACKOFF_MAX", 5*time.Minute),
+		FailureWindow:  getEnvAsDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		Threshold:      getEnvAsInt("LOCKOUT_THRESHOLD", 10),
+		Duration:       getEnvAsDuration("LOCKOUT_DURATION", 15*time.Minute),
+	}
+
 	return SSOConfig{
 		IssuerURL:              getEnv("ISSUER_URL", "http://localhost:3000"),
 		DefaultScopes:          getEnvAsStringSlice("DEFAULT_SCOPES", []string{"openid", "profile", "email"}),
 		PKCERequired:           getEnvAsBool("PKCE_REQUIRED", true),
+		SigningAlgorithm:       strings.ToUpper(getEnv("SIGNING_ALGORITHM", "RS256")),
 		SessionTTL:             getEnvAsDuration("SESSION_TTL", 24*time.Hour),
+		SessionIdleTimeout:     getEnvAsDuration("SESSION_IDLE_TIMEOUT", 2*time.Hour),
 		LoginStateTTL:          getEnvAsDuration("LOGIN_STATE_TTL", 15*time.Minute),
+		EmailVerificationTTL:   getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		EmailResendInterval:    getEnvAsDuration("EMAIL_RESEND_INTERVAL", time.Minute),
+		PasswordResetTTL:       getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+		EmailLoginTTL:          getEnvAsDuration("EMAIL_LOGIN_TTL", 10*time.Minute),
 		DeviceCodeTTL:          getEnvAsDuration("DEVICE_CODE_TTL", time.Hour),
 		DeviceCodePollInterval: getEnvAsDuration("DEVICE_CODE_POLL_INTERVAL", 5*time.Second),
+		MFAChallengeTTL:        getEnvAsDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		MFAEncryptionKey:       getEnv("MFA_ENCRYPTION_KEY", ""),
+		MFAIssuer:              getEnv("MFA_ISSUER", "Passport"),
+		ScopeMinimumACR:        getEnvAsStringMap("SCOPE_MINIMUM_ACR", map[string]string{"payments:write": "urn:passport:acr:mfa"}),
 		Cookie:                 cookie,
 		Tokens:                 tokens,
+		Lockout:                lockout,
 	}
 }
 
@@ -178,3 +255,27 @@ func getEnvAsStringSlice(key string, fallback []string) []string {
 
 	return out
 }
+
+// getEnvAsStringMap parses comma separated key=value pairs.
+func getEnvAsStringMap(key string, fallback map[string]string) map[string]string {
+	values := getEnvAsStringSlice(key, nil)
+	if values == nil {
+		return fallback
+	}
+
+	out := make(map[string]string, len(values))
+	for _, pair := range values {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		out[k] = v
+	}
+
+	if len(out) == 0 {
+		return fallback
+	}
+
+	return out
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+// authenticatedUserKey is the gin context key under which authentication
+// middleware stores the end-user bound to the current request.
+const authenticatedUserKey = "authenticated_user"
+
+type authenticatedUser struct {
+	ID        uuid.UUID
+	SessionID string
+	AuthTime  time.Time
+	AMR       []string
+	ACR       string
+}
+
+func (u *authenticatedUser) authentication() services.Authentication {
+	return services.Authentication{
+		UserID:    u.ID,
+		SessionID: u.SessionID,
+		AuthTime:  u.AuthTime,
+		AMR:       u.AMR,
+		ACR:       u.ACR,
+	}
+}
+
+func currentUser(c *gin.Context) (*authenticatedUser, bool) {
+	value, ok := c.Get(authenticatedUserKey)
+	if !ok {
+		return nil, false
+	}
+
+	user, ok := value.(*authenticatedUser)
+	return user, ok && user != nil
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type AuthorizeHandler struct {
+	service services.AuthorizationService
+	mfa     services.MFAService
+}
+
+type authorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Prompt              string `form:"prompt"`
+	MaxAge              string `form:"max_age"`
+	ACRValues           string `form:"acr_values"`
+}
+
+func NewAuthorizeHandler(service services.AuthorizationService, mfa services.MFAService) *AuthorizeHandler {
+	return &AuthorizeHandler{service: service, mfa: mfa}
+}
+
+func (h *AuthorizeHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/authorize", h.Authorize)
+	router.POST("/authorize", h.Authorize)
+}
+
+func (h *AuthorizeHandler) Authorize(c *gin.Context) {
+	var req authorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+		return
+	}
+
+	authReq, err := h.service.ValidateRequest(c.Request.Context(), services.AuthorizeParams{
+		ResponseType:        req.ResponseType,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		State:               req.State,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Prompt:              req.Prompt,
+		MaxAge:              req.MaxAge,
+		ACRValues:           req.ACRValues,
+	})
+	if err != nil {
+		var oauthErr *services.OAuthError
+		switch {
+		case errors.Is(err, services.ErrInvalidAuthorizeClient), errors.Is(err, services.ErrRedirectURIMismatch):
+			writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+		case errors.As(err, &oauthErr):
+			redirectWithError(c, authReq, oauthErr)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	// An existing IdP session satisfies the request unless the client demands a
+	// fresh login, the session is older than max_age, or its ACR is too weak.
+	if user, ok := currentUser(c); ok {
+		switch services.EvaluateSession(authReq, user.authentication(), time.Now()) {
+		case services.SessionSatisfies:
+			h.issueCode(c, authReq, user)
+			return
+		case services.SessionStepUp:
+			h.stepUp(c, authReq, user)
+			return
+		}
+	}
+
+	if authReq.Prompt == "none" {
+		redirectWithError(c, authReq, &services.OAuthError{Code: services.OAuthErrorLoginRequired})
+		return
+	}
+
+	// Park the request until the end-user has authenticated; login resumes it
+	// using the returned login_state.
+	loginState, err := h.service.SaveLoginState(c.Request.Context(), authReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":         services.OAuthErrorLoginRequired,
+		"login_state":   loginState,
+		"login_methods": authReq.LoginMethods,
+	})
+}
+
+func (h *AuthorizeHandler) issueCode(c *gin.Context, authReq *services.AuthorizationRequest, user *authenticatedUser) {
+	code, err := h.service.IssueCode(c.Request.Context(), authReq, user.authentication())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	params := url.Values{"code": {code}}
+	if authReq.State != "" {
+		params.Set("state", authReq.State)
+	}
+	redirectWithParams(c, authReq.RedirectURI, params)
+}
+
+// stepUp raises a recent session to the required ACR with an MFA challenge
+// instead of a full login. The challenge is bound to the session, which is
+// upgraded in place and keeps its sid and participating clients.
+func (h *AuthorizeHandler) stepUp(c *gin.Context, authReq *services.AuthorizationRequest, user *authenticatedUser) {
+	ctx := c.Request.Context()
+
+	if authReq.Prompt == "none" {
+		redirectWithError(c, authReq, &services.OAuthError{Code: services.OAuthErrorLoginRequired})
+		return
+	}
+
+	methods, err := h.mfa.Methods(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if len(methods) == 0 {
+		redirectWithError(c, authReq, &services.OAuthError{
+			Code:        services.OAuthErrorAccessDenied,
+			Description: "multi-factor authentication is required",
+		})
+		return
+	}
+
+	loginState, err := h.service.SaveLoginState(ctx, authReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	token, err := h.mfa.StartChallenge(ctx, services.StartChallengeParams{
+		UserID:     user.ID,
+		LoginState: loginState,
+		AMR:        user.AMR,
+		SessionID:  user.SessionID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":        services.OAuthErrorLoginRequired,
+		"login_state":  loginState,
+		"mfa_required": true,
+		"mfa_token":    token,
+		"mfa_methods":  methods,
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+const accessTokenClaimsKey = "access_token_claims"
+
+var errMultipleBearerTokens = errors.New("access token must be sent using exactly one method")
+
+// RequireAccessToken is the resource-server middleware for endpoints that
+// accept bearer tokens issued by this server (RFC 6750). The validated claims
+// are available to later handlers through accessTokenClaims.
+func RequireAccessToken(tokens services.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := bearerTokenFromRequest(c)
+		if err != nil {
+			writeBearerError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+			return
+		}
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="passport"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := tokens.ValidateAccessToken(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAccessToken) {
+				writeBearerError(c, http.StatusUnauthorized, "invalid_token", err.Error())
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+
+		c.Set(accessTokenClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose access token was not granted scope. It
+// must run after RequireAccessToken.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := accessTokenClaims(c)
+		if !ok {
+			writeBearerError(c, http.StatusUnauthorized, "invalid_token", services.ErrInvalidAccessToken.Error())
+			return
+		}
+
+		if !slices.Contains(strings.Fields(claims.Scope), scope) {
+			writeBearerError(c, http.StatusForbidden, "insufficient_scope", "the "+scope+" scope is required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireClientToken rejects access tokens issued on behalf of a user; only
+// client_credentials tokens, whose subject is the client itself, pass. It
+// must run after RequireAccessToken.
+func RequireClientToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := accessTokenClaims(c)
+		if !ok {
+			writeBearerError(c, http.StatusUnauthorized, "invalid_token", services.ErrInvalidAccessToken.Error())
+			return
+		}
+
+		if claims.ClientID == "" || claims.Subject != claims.ClientID {
+			writeBearerError(c, http.StatusForbidden, "insufficient_scope", "a client credentials token is required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin guards the administrative API: a valid client credentials
+// access token carrying the admin scope.
+func RequireAdmin(tokens services.TokenService) []gin.HandlerFunc {
+	return []gin.HandlerFunc{RequireAccessToken(tokens), RequireClientToken(), RequireScope(services.ScopeAdmin)}
+}
+
+func accessTokenClaims(c *gin.Context) (*services.AccessTokenClaims, bool) {
+	value, ok := c.Get(accessTokenClaimsKey)
+	if !ok {
+		return nil, false
+	}
+
+	claims, ok := value.(*services.AccessTokenClaims)
+	return claims, ok && claims != nil
+}
+
+// bearerTokenFromRequest reads the token from the Authorization header or,
+// for form-encoded POST bodies, the access_token parameter.
+func bearerTokenFromRequest(c *gin.Context) (string, error) {
+	var token string
+
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, value, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(value) == "" {
+			return "", errors.New("malformed authorization header")
+		}
+		token = strings.TrimSpace(value)
+	}
+
+	if c.Request.Method == http.MethodPost && c.ContentType() == "application/x-www-form-urlencoded" {
+		if formToken := c.PostForm("access_token"); formToken != "" {
+			if token != "" {
+				return "", errMultipleBearerTokens
+			}
+			token = formToken
+		}
+	}
+
+	return token, nil
+}
+
+func writeBearerError(c *gin.Context, status int, code, description string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="passport", error=%q, error_description=%q`, code, description))
+	c.AbortWithStatusJSON(status, oauthErrorResponse{Error: code, ErrorDescription: description})
+}
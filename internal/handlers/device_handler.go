@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type DeviceHandler struct {
+	clients services.ClientService
+	devices services.DeviceAuthorizationService
+}
+
+type deviceAuthorizationRequest struct {
+	Scope string `form:"scope"`
+}
+
+type deviceDecisionRequest struct {
+	UserCode string `json:"user_code" binding:"required"`
+	Approve  bool   `json:"approve"`
+}
+
+type deviceVerificationResponse struct {
+	UserCode   string   `json:"user_code"`
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+}
+
+func NewDeviceHandler(clients services.ClientService, devices services.DeviceAuthorizationService) *DeviceHandler {
+	return &DeviceHandler{clients: clients, devices: devices}
+}
+
+func (h *DeviceHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/device_authorization", h.StartDeviceAuthorization)
+	router.GET("/device", h.GetVerification)
+	router.POST("/device", h.Decide)
+}
+
+func (h *DeviceHandler) StartDeviceAuthorization(c *gin.Context) {
+	var req deviceAuthorizationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+		return
+	}
+
+	client, err := authenticateClient(c, h.clients)
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	response, err := h.devices.Start(c.Request.Context(), client, req.Scope)
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// GetVerification describes the pending request behind a user code so the
+// end-user can check what they are about to approve.
+func (h *DeviceHandler) GetVerification(c *gin.Context) {
+	if !h.limitAttempts(c) {
+		return
+	}
+
+	authorization, err := h.devices.Lookup(c.Request.Context(), c.Query("user_code"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserCodeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	client, err := h.clients.GetClientByClientID(c.Request.Context(), authorization.ClientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deviceVerificationResponse{
+		UserCode:   c.Query("user_code"),
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     authorization.Scopes,
+	})
+}
+
+func (h *DeviceHandler) Decide(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	var req deviceDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.limitAttempts(c) {
+		return
+	}
+
+	var err error
+	if req.Approve {
+		err = h.devices.Approve(c.Request.Context(), req.UserCode, user.authentication())
+	} else {
+		err = h.devices.Deny(c.Request.Context(), req.UserCode)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserCodeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeviceCodeNotPending):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// limitAttempts writes the error response and returns false once the caller
+// has used up its user code attempts.
+func (h *DeviceHandler) limitAttempts(c *gin.Context) bool {
+	if err := h.devices.LimitAttempts(c.Request.Context(), c.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrUserCodeRateLimited) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type DiscoveryHandler struct {
+	cfg  config.SSOConfig
+	keys keys.Manager
+}
+
+type tokenLifetimes struct {
+	AuthorizationCode int64 `json:"authorization_code"`
+	AccessToken       int64 `json:"access_token"`
+	RefreshToken      int64 `json:"refresh_token"`
+	IDToken           int64 `json:"id_token"`
+}
+
+type discoveryDocument struct {
+	Issuer                             string         `json:"issuer"`
+	AuthorizationEndpoint              string         `json:"authorization_endpoint"`
+	TokenEndpoint                      string         `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint        string         `json:"device_authorization_endpoint"`
+	UserInfoEndpoint                   string         `json:"userinfo_endpoint"`
+	IntrospectionEndpoint              string         `json:"introspection_endpoint"`
+	RevocationEndpoint                 string         `json:"revocation_endpoint"`
+	EndSessionEndpoint                 string         `json:"end_session_endpoint"`
+	JWKSURI                            string         `json:"jwks_uri"`
+	ScopesSupported                    []string       `json:"scopes_supported"`
+	ResponseTypesSupported             []string       `json:"response_types_supported"`
+	GrantTypesSupported                []string       `json:"grant_types_supported"`
+	SubjectTypesSupported              []string       `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported   []string       `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported      []string       `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethodsSupported  []string       `json:"token_endpoint_auth_methods_supported"`
+	ClaimsSupported                    []string       `json:"claims_supported"`
+	ACRValuesSupported                 []string       `json:"acr_values_supported"`
+	BackchannelLogoutSupported         bool           `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported  bool           `json:"backchannel_logout_session_supported"`
+	FrontchannelLogoutSupported        bool           `json:"frontchannel_logout_supported"`
+	FrontchannelLogoutSessionSupported bool           `json:"frontchannel_logout_session_supported"`
+	TokenLifetimes                     tokenLifetimes `json:"token_lifetimes"`
+}
+
+func NewDiscoveryHandler(cfg config.SSOConfig, keys keys.Manager) *DiscoveryHandler {
+	return &DiscoveryHandler{cfg: cfg, keys: keys}
+}
+
+func (h *DiscoveryHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/jwks.json", h.GetJWKS)
+	router.GET("/openid-configuration", h.GetConfiguration)
+}
+
+func (h *DiscoveryHandler) GetJWKS(c *gin.Context) {
+	set, err := h.keys.JWKS(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
+
+func (h *DiscoveryHandler) GetConfiguration(c *gin.Context) {
+	scopes := h.cfg.DefaultScopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	doc := discoveryDocument{
+		Issuer:                      issuerURL(h.cfg),
+		AuthorizationEndpoint:       endpointURL(h.cfg, "/authorize"),
+		TokenEndpoint:               endpointURL(h.cfg, "/token"),
+		DeviceAuthorizationEndpoint: endpointURL(h.cfg, "/device_authorization"),
+		UserInfoEndpoint:            endpointURL(h.cfg, "/userinfo"),
+		IntrospectionEndpoint:       endpointURL(h.cfg, "/introspect"),
+		RevocationEndpoint:          endpointURL(h.cfg, "/revoke"),
+		EndSessionEndpoint:          endpointURL(h.cfg, "/end_session"),
+		JWKSURI:                     endpointURL(h.cfg, "/.well-known/jwks.json"),
+		ScopesSupported:             scopes,
+		ResponseTypesSupported:      []string{"code"},
+		GrantTypesSupported: []string{
+			services.GrantTypeAuthorizationCode,
+			services.GrantTypeRefreshToken,
+			services.GrantTypeClientCredentials,
+			services.GrantTypeDeviceCode,
+		},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{string(h.keys.Algorithm())},
+		CodeChallengeMethodsSupported:    []string{services.CodeChallengeMethodS256},
+		TokenEndpointAuthMethodsSupported: []string{
+			string(models.ClientAuthMethodSecretBasic),
+			string(models.ClientAuthMethodSecretPost),
+			string(models.ClientAuthMethodNone),
+		},
+		ClaimsSupported:                    slices.Concat(services.SupportedUserClaims, []string{"auth_time", "acr", "amr"}),
+		ACRValuesSupported:                 services.SupportedACRValues,
+		BackchannelLogoutSupported:         true,
+		BackchannelLogoutSessionSupported:  true,
+		FrontchannelLogoutSupported:        true,
+		FrontchannelLogoutSessionSupported: true,
+		TokenLifetimes: tokenLifetimes{
+			AuthorizationCode: int64(h.cfg.Tokens.AuthorizationCode.Seconds()),
+			AccessToken:       int64(h.cfg.Tokens.AccessToken.Seconds()),
+			RefreshToken:      int64(h.cfg.Tokens.RefreshToken.Seconds()),
+			IDToken:           int64(h.cfg.Tokens.IDToken.Seconds()),
+		},
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, doc)
+}
+
+func issuerURL(cfg config.SSOConfig) string {
+	return strings.TrimRight(cfg.IssuerURL, "/")
+}
+
+func endpointURL(cfg config.SSOConfig, path string) string {
+	return issuerURL(cfg) + path
+}
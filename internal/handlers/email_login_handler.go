@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type startEmailLoginRequest struct {
+	Email      string             `json:"email" binding:"required"`
+	Method     models.LoginMethod `json:"method" binding:"required"`
+	LoginState string             `json:"login_state"`
+}
+
+type verifyEmailCodeRequest struct {
+	LoginToken string `json:"login_token" binding:"required"`
+	Code       string `json:"code" binding:"required"`
+}
+
+// StartEmailLogin emails a sign-in link or a one-time code. The response is
+// the same whether or not the address belongs to an account.
+func (h *LoginHandler) StartEmailLogin(c *gin.Context) {
+	var req startEmailLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authReq, ok := h.checkLoginMethod(c, req.LoginState, req.Method)
+	if !ok {
+		return
+	}
+
+	// The email is branded for the client the user is signing in to.
+	clientID := ""
+	if authReq != nil {
+		clientID = authReq.ClientID
+	}
+
+	loginToken, err := h.emailLogin.Start(c.Request.Context(), services.StartEmailLoginParams{
+		Email:      req.Email,
+		Method:     req.Method,
+		ClientID:   clientID,
+		LoginState: req.LoginState,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedEmailMethod), errors.Is(err, services.ErrEmailLoginInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailLoginRateLimited):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	response := gin.H{"message": "if the address belongs to an account, a sign-in email is on its way"}
+	if loginToken != "" {
+		response["login_token"] = loginToken
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// VerifyEmailLink signs the user in from the link in their inbox.
+func (h *LoginHandler) VerifyEmailLink(c *gin.Context) {
+	result, err := h.emailLogin.VerifyLink(c.Request.Context(), c.Query("token"))
+	h.finishEmailLogin(c, result, err)
+}
+
+// VerifyEmailCode signs the user in with the code from their inbox.
+func (h *LoginHandler) VerifyEmailCode(c *gin.Context) {
+	var req verifyEmailCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.emailLogin.VerifyCode(c.Request.Context(), req.LoginToken, req.Code)
+	h.finishEmailLogin(c, result, err)
+}
+
+func (h *LoginHandler) finishEmailLogin(c *gin.Context, result *services.EmailLoginResult, err error) {
+	if err != nil {
+		if errors.Is(err, services.ErrEmailLoginInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	user, ok := h.signInUser(c, result.UserID)
+	if !ok {
+		return
+	}
+
+	h.completeFirstFactor(c, user, result.LoginState, result.AMR)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type EmailOutboxHandler struct {
+	service services.EmailOutboxService
+}
+
+// outboxMessageResponse leaves out the subject and bodies: they can carry
+// sign-in codes and single-use links.
+type outboxMessageResponse struct {
+	ID         string   `json:"id"`
+	Template   string   `json:"template"`
+	Recipients []string `json:"recipients"`
+	Status     string   `json:"status"`
+	Attempts   int      `json:"attempts"`
+	LastError  string   `json:"last_error"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+func NewEmailOutboxHandler(service services.EmailOutboxService) *EmailOutboxHandler {
+	return &EmailOutboxHandler{service: service}
+}
+
+func (h *EmailOutboxHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/stats", h.Stats)
+	router.GET("/failed", h.ListFailed)
+	router.POST("/:id/replay", h.Replay)
+}
+
+func (h *EmailOutboxHandler) Stats(c *gin.Context) {
+	stats, err := h.service.Stats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *EmailOutboxHandler) ListFailed(c *gin.Context) {
+	limit, err := parseQueryInt(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	offset, err := parseQueryInt(c, "offset")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+
+	messages, err := h.service.ListFailed(c.Request.Context(), services.ListOutboxFilter{Limit: limit, Offset: offset})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	responses := make([]outboxMessageResponse, 0, len(messages))
+	for i := range messages {
+		responses = append(responses, toOutboxMessageResponse(&messages[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": responses})
+}
+
+func (h *EmailOutboxHandler) Replay(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	if err := h.service.Replay(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repositories.ErrOutboxMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "failed message not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func toOutboxMessageResponse(message *models.EmailOutboxMessage) outboxMessageResponse {
+	return outboxMessageResponse{
+		ID:         message.ID.String(),
+		Template:   message.Template,
+		Recipients: message.Recipients,
+		Status:     string(message.Status),
+		Attempts:   message.Attempts,
+		LastError:  message.LastError,
+		CreatedAt:  message.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:  message.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
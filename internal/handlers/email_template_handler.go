@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+// EmailTemplateHandler lets admins inspect transactional emails as users
+// would receive them.
+type EmailTemplateHandler struct {
+	templates *mailer.Templates
+	clients   services.ClientService
+}
+
+type emailPreviewResponse struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text"`
+	HTML     string `json:"html"`
+}
+
+func NewEmailTemplateHandler(templates *mailer.Templates, clients services.ClientService) *EmailTemplateHandler {
+	return &EmailTemplateHandler{templates: templates, clients: clients}
+}
+
+func (h *EmailTemplateHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("", h.ListTemplates)
+	router.GET("/:name/preview", h.Preview)
+}
+
+func (h *EmailTemplateHandler) ListTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"templates": mailer.TemplateNames,
+		"locales":   mailer.SupportedLocales,
+	})
+}
+
+// Preview renders a template with sample data. locale and client_id pick the
+// variant and branding; format selects html (default), text or json.
+func (h *EmailTemplateHandler) Preview(c *gin.Context) {
+	name := mailer.TemplateName(c.Param("name"))
+
+	brand := mailer.DefaultBranding
+	if clientID := c.Query("client_id"); clientID != "" {
+		client, err := h.clients.GetClientByClientID(c.Request.Context(), clientID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrClientNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		brand = services.ClientBranding(client)
+	}
+
+	locale := mailer.ResolveLocale(c.Query("locale"))
+	msg, err := h.templates.Render(name, locale, brand, mailer.SampleData(name))
+	if err != nil {
+		if errors.Is(err, mailer.ErrUnknownTemplate) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "html") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+	case "json":
+		c.JSON(http.StatusOK, emailPreviewResponse{
+			Template: string(name),
+			Locale:   locale,
+			Subject:  msg.Subject,
+			Text:     msg.Text,
+			HTML:     msg.HTML,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html, text or json"})
+	}
+}
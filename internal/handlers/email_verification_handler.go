@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+var emailVerificationTemplate = template.Must(template.New("email_verification").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Verify your email address</title>
+</head>
+<body>
+<form id="verify" method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Verify email address</button>
+</form>
+<p id="result"></p>
+<script>
+(function () {
+  var form = document.getElementById("verify");
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    fetch(form.action, { method: "POST", body: new URLSearchParams(new FormData(form)) })
+      .then(function (response) { return response.json(); })
+      .then(function (result) {
+        document.getElementById("result").textContent = result.message || result.error;
+        if (result.message) { form.remove(); }
+      });
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+type emailVerificationPage struct {
+	Action string
+	Token  string
+}
+
+type EmailVerificationHandler struct {
+	service services.EmailVerificationService
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+type resendVerificationRequest struct {
+	Email    string `json:"email" form:"email" binding:"required,email"`
+	ClientID string `json:"client_id" form:"client_id"`
+}
+
+func NewEmailVerificationHandler(service services.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{service: service}
+}
+
+func (h *EmailVerificationHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET(services.EmailVerificationPath, h.Confirm)
+	router.POST(services.EmailVerificationPath, h.Verify)
+	router.POST(services.EmailVerificationPath+"/resend", h.Resend)
+}
+
+// Confirm is where emailed verification links land. Mail scanners fetch
+// links before the user sees them, so the page only asks the user to confirm
+// and the token is redeemed by the POST it sends.
+func (h *EmailVerificationHandler) Confirm(c *gin.Context) {
+	var body bytes.Buffer
+	if err := emailVerificationTemplate.Execute(&body, emailVerificationPage{
+		Action: services.EmailVerificationPath,
+		Token:  c.Query("token"),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
+
+func (h *EmailVerificationHandler) Verify(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.Verify(c.Request.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrVerificationTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "email verified",
+		"status":  user.Status,
+	})
+}
+
+// Resend always answers 202 so the response does not reveal whether the
+// address belongs to an unverified account.
+func (h *EmailVerificationHandler) Resend(c *gin.Context) {
+	var req resendVerificationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Resend(c.Request.Context(), req.Email, req.ClientID); err != nil {
+		if errors.Is(err, services.ErrResendRateLimited) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account needs verification, an email is on its way"})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/sessions"
+)
+
+var endSessionConfirmTemplate = template.Must(template.New("end_session_confirm").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sign out</title>
+</head>
+<body>
+<p>Do you want to sign out{{if .ClientName}} of {{.ClientName}} and all other applications{{end}}?</p>
+<form method="post" action="/end_session">
+<input type="hidden" name="confirmation" value="{{.Confirmation}}">
+{{if .IDTokenHint}}<input type="hidden" name="id_token_hint" value="{{.IDTokenHint}}">
+{{end}}{{if .ClientID}}<input type="hidden" name="client_id" value="{{.ClientID}}">
+{{end}}{{if .PostLogoutRedirectURI}}<input type="hidden" name="post_logout_redirect_uri" value="{{.PostLogoutRedirectURI}}">
+{{end}}{{if .State}}<input type="hidden" name="state" value="{{.State}}">
+{{end}}<button type="submit">Sign out</button>
+</form>
+</body>
+</html>
+`))
+
+type endSessionConfirmPage struct {
+	Confirmation          string
+	IDTokenHint           string
+	ClientID              string
+	ClientName            string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+type EndSessionHandler struct {
+	logout services.LogoutService
+	cfg    config.SSOConfig
+}
+
+type endSessionRequest struct {
+	IDTokenHint           string `form:"id_token_hint"`
+	ClientID              string `form:"client_id"`
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri"`
+	State                 string `form:"state"`
+	Confirmation          string `form:"confirmation"`
+}
+
+func NewEndSessionHandler(logout services.LogoutService, cfg config.SSOConfig) *EndSessionHandler {
+	return &EndSessionHandler{logout: logout, cfg: cfg}
+}
+
+func (h *EndSessionHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/end_session", h.EndSession)
+	router.POST("/end_session", h.EndSession)
+}
+
+func (h *EndSessionHandler) EndSession(c *gin.Context) {
+	var req endSessionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endSession, err := h.logout.ValidateEndSession(c.Request.Context(), services.EndSessionParams{
+		IDTokenHint:           req.IDTokenHint,
+		ClientID:              req.ClientID,
+		PostLogoutRedirectURI: req.PostLogoutRedirectURI,
+		State:                 req.State,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidIDTokenHint),
+			errors.Is(err, services.ErrEndSessionClientMismatch),
+			errors.Is(err, services.ErrEndSessionUnknownClient),
+			errors.Is(err, services.ErrPostLogoutRedirectURIMismatch),
+			errors.Is(err, services.ErrPostLogoutRedirectUnbound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	// Only an id_token_hint issued for this browser's session or user shows
+	// the request came from a relying party the user signed in to. Anything
+	// else, including a validly signed hint for someone else, could be a
+	// cross-site link, so ask the user first and only act on the confirmed
+	// POST.
+	if user, ok := currentUser(c); ok && !hintMatchesUser(endSession, user) {
+		if c.Request.Method != http.MethodPost || req.Confirmation == "" {
+			h.renderConfirmation(c, user.SessionID, req, endSession)
+			return
+		}
+		if err := h.logout.ConfirmLogout(c.Request.Context(), user.SessionID, req.Confirmation); err != nil {
+			if errors.Is(err, services.ErrLogoutConfirmationInvalid) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+	}
+
+	frontchannelURLs, err := h.logout.TerminateSession(c.Request.Context(), endSession.SessionID, endSession.Subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	// The browser's own session ends too, even when the id_token_hint named a
+	// different (possibly already expired) one.
+	if user, ok := currentUser(c); ok && user.SessionID != endSession.SessionID {
+		urls, err := h.logout.TerminateSession(c.Request.Context(), user.SessionID, user.ID.String())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		frontchannelURLs = append(frontchannelURLs, urls...)
+	}
+
+	sessions.ClearCookie(c.Writer, h.cfg.Cookie)
+
+	if len(frontchannelURLs) > 0 {
+		renderFrontchannelLogout(c, frontchannelURLs, postLogoutRedirect(endSession))
+		return
+	}
+
+	if endSession.PostLogoutRedirectURI == "" {
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, postLogoutRedirect(endSession))
+}
+
+func (h *EndSessionHandler) renderConfirmation(c *gin.Context, sessionID string, req endSessionRequest, endSession *services.EndSessionRequest) {
+	confirmation, err := h.logout.StartLogoutConfirmation(c.Request.Context(), sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	page := endSessionConfirmPage{
+		Confirmation:          confirmation,
+		IDTokenHint:           req.IDTokenHint,
+		ClientID:              req.ClientID,
+		PostLogoutRedirectURI: endSession.PostLogoutRedirectURI,
+		State:                 req.State,
+	}
+	if endSession.Client != nil {
+		page.ClientName = endSession.Client.Name
+	}
+
+	var body bytes.Buffer
+	if err := endSessionConfirmTemplate.Execute(&body, page); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
+
+// hintMatchesUser reports whether the validated id_token_hint was issued for
+// user's current session or for user.
+func hintMatchesUser(endSession *services.EndSessionRequest, user *authenticatedUser) bool {
+	if endSession.SessionID != "" && endSession.SessionID == user.SessionID {
+		return true
+	}
+	return endSession.Subject != "" && endSession.Subject == user.ID.String()
+}
+
+// postLogoutRedirect returns the post-logout redirect with state echoed, or
+// an empty string when the relying party did not ask to be sent back.
+func postLogoutRedirect(endSession *services.EndSessionRequest) string {
+	if endSession.PostLogoutRedirectURI == "" {
+		return ""
+	}
+
+	target, err := url.Parse(endSession.PostLogoutRedirectURI)
+	if err != nil {
+		return ""
+	}
+
+	if endSession.State != "" {
+		query := target.Query()
+		query.Set("state", endSession.State)
+		target.RawQuery = query.Encode()
+	}
+
+	return target.String()
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// frontchannelLogoutTimeoutMillis bounds how long the page waits for relying
+// parties before continuing, so one slow client cannot strand the user.
+const frontchannelLogoutTimeoutMillis = 3000
+
+var frontchannelLogoutTemplate = template.Must(template.New("frontchannel_logout").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Signing out</title>
+</head>
+<body>
+<p>Signing you out of all applications&hellip;</p>
+{{range .LogoutURLs}}<iframe src="{{.}}" style="display:none" width="0" height="0"></iframe>
+{{end}}<script>
+(function () {
+  var redirect = {{.RedirectURI}};
+  var frames = document.getElementsByTagName("iframe");
+  var pending = frames.length;
+  var done = false;
+  function finish() {
+    if (done) { return; }
+    done = true;
+    if (redirect) { window.location.replace(redirect); }
+    else { document.body.innerHTML = "<p>You have been signed out.</p>"; }
+  }
+  for (var i = 0; i < frames.length; i++) {
+    frames[i].onload = function () { pending--; if (pending <= 0) { finish(); } };
+  }
+  setTimeout(finish, {{.TimeoutMillis}});
+})();
+</script>
+</body>
+</html>
+`))
+
+type frontchannelLogoutPage struct {
+	LogoutURLs    []string
+	RedirectURI   string
+	TimeoutMillis int
+}
+
+// renderFrontchannelLogout serves the page that loads every front-channel
+// logout URL in a hidden iframe, then continues to redirectURI if set.
+func renderFrontchannelLogout(c *gin.Context, logoutURLs []string, redirectURI string) {
+	var body bytes.Buffer
+	err := frontchannelLogoutTemplate.Execute(&body, frontchannelLogoutPage{
+		LogoutURLs:    logoutURLs,
+		RedirectURI:   redirectURI,
+		TimeoutMillis: frontchannelLogoutTimeoutMillis,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
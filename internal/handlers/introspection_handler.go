@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type IntrospectionHandler struct {
+	clients       services.ClientService
+	introspection services.IntrospectionService
+}
+
+type introspectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+func NewIntrospectionHandler(clients services.ClientService, introspection services.IntrospectionService) *IntrospectionHandler {
+	return &IntrospectionHandler{clients: clients, introspection: introspection}
+}
+
+func (h *IntrospectionHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/introspect", h.Introspect)
+}
+
+func (h *IntrospectionHandler) Introspect(c *gin.Context) {
+	var req introspectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+		return
+	}
+
+	client, err := authenticateClient(c, h.clients)
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	response, err := h.introspection.Introspect(c.Request.Context(), client, req.Token, req.TokenTypeHint)
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
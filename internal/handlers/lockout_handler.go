@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+// LockoutHandler lets admins lift a failed-login lockout before it expires.
+type LockoutHandler struct {
+	service services.LoginThrottleService
+}
+
+func NewLockoutHandler(service services.LoginThrottleService) *LockoutHandler {
+	return &LockoutHandler{service: service}
+}
+
+// RegisterUserRoutes mounts the admin endpoints on the /users group.
+func (h *LockoutHandler) RegisterUserRoutes(router *gin.RouterGroup) {
+	router.POST("/:id/unlock", h.UnlockUser)
+}
+
+func (h *LockoutHandler) UnlockUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Unlock(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/sessions"
+	"github.com/mohammadhprp/passport/internal/webauthn"
+)
+
+var errLoginMethodNotAllowed = errors.New("login method is not enabled for this client")
+
+type LoginHandler struct {
+	users          services.UserService
+	authorizations services.AuthorizationService
+	sessions       sessions.Store
+	logout         services.LogoutService
+	mfa            services.MFAService
+	webAuthn       services.WebAuthnService
+	emailLogin     services.EmailLoginService
+	throttle       services.LoginThrottleService
+	cfg            config.SSOConfig
+}
+
+type loginRequest struct {
+	Email      string `json:"email" form:"email" binding:"required"`
+	Password   string `json:"password" form:"password" binding:"required"`
+	LoginState string `json:"login_state" form:"login_state"`
+}
+
+// mfaLoginRequest carries a code for totp and backup_code, or the
+// PublicKeyCredential JSON for webauthn.
+type mfaLoginRequest struct {
+	MFAToken   string                      `json:"mfa_token" binding:"required"`
+	Method     string                      `json:"method" binding:"required"`
+	Code       string                      `json:"code"`
+	Credential *webauthn.AssertionResponse `json:"credential"`
+}
+
+type mfaWebAuthnOptionsRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type webAuthnLoginRequest struct {
+	Credential *webauthn.AssertionResponse `json:"credential" binding:"required"`
+	LoginState string                      `json:"login_state"`
+}
+
+// mfaRequiredResponse tells the UI to prompt for a second factor and post it
+// with mfa_token to /login/mfa.
+type mfaRequiredResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	MFAMethods  []string `json:"mfa_methods"`
+}
+
+type loginResponse struct {
+	UserID           string    `json:"user_id"`
+	SessionExpiresAt time.Time `json:"session_expires_at"`
+	RedirectTo       string    `json:"redirect_to,omitempty"`
+}
+
+type logoutResponse struct {
+	Message                string   `json:"message"`
+	FrontchannelLogoutURIs []string `json:"frontchannel_logout_uris,omitempty"`
+}
+
+func NewLoginHandler(
+	users services.UserService,
+	authorizations services.AuthorizationService,
+	sessions sessions.Store,
+	logout services.LogoutService,
+	mfa services.MFAService,
+	webAuthn services.WebAuthnService,
+	emailLogin services.EmailLoginService,
+	throttle services.LoginThrottleService,
+	cfg config.SSOConfig,
+) *LoginHandler {
+	return &LoginHandler{
+		users:          users,
+		authorizations: authorizations,
+		sessions:       sessions,
+		logout:         logout,
+		mfa:            mfa,
+		webAuthn:       webAuthn,
+		emailLogin:     emailLogin,
+		throttle:       throttle,
+		cfg:            cfg,
+	}
+}
+
+func (h *LoginHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/login", h.Login)
+	router.POST("/login/mfa", h.LoginMFA)
+	router.POST("/login/mfa/webauthn/options", h.MFAWebAuthnOptions)
+	router.POST("/login/webauthn/options", h.WebAuthnOptions)
+	router.POST("/login/webauthn", h.LoginWebAuthn)
+	router.POST("/login/email", h.StartEmailLogin)
+	router.GET("/login/email/verify", h.VerifyEmailLink)
+	router.POST("/login/email/verify", h.VerifyEmailCode)
+	router.POST("/logout", h.Logout)
+}
+
+// Login verifies the user's password, starts an IdP session and, when a
+// login_state is given, resumes the parked /authorize request. Users with
+// MFA enabled get a challenge token instead and finish at /login/mfa.
+func (h *LoginHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := h.checkLoginMethod(c, req.LoginState, models.LoginMethodPassword); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Throttling is keyed by the submitted email, so a locked account and an
+	// unknown one get the same response.
+	if err := h.throttle.Check(ctx, req.Email, c.ClientIP()); err != nil {
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	user, err := h.users.AuthenticateUser(ctx, req.Email, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			if err := h.throttle.RecordFailure(ctx, req.Email, c.ClientIP()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserDisabled), errors.Is(err, services.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	if err := h.throttle.RecordSuccess(ctx, req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	h.completeFirstFactor(c, user, req.LoginState, []string{services.AMRPassword})
+}
+
+// LoginMFA finishes a login that was paused for a second factor.
+func (h *LoginHandler) LoginMFA(c *gin.Context) {
+	var req mfaLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	result, err := h.mfa.CompleteChallenge(ctx, req.MFAToken, services.MFAProof{
+		Method:    req.Method,
+		Code:      req.Code,
+		Assertion: req.Credential,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedMFAMethod):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrMFAChallengeInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	// A step-up challenge only upgrades the session it was started for; the
+	// second factor alone must never produce a new session.
+	if result.SessionID != "" {
+		if current, ok := currentUser(c); !ok || current.SessionID != result.SessionID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrMFAChallengeInvalid.Error()})
+			return
+		}
+	}
+
+	// The account may have been disabled while the challenge was pending.
+	user, ok := h.signInUser(c, result.UserID)
+	if !ok {
+		return
+	}
+
+	h.completeLogin(c, user, result.LoginState, result.AMR)
+}
+
+// MFAWebAuthnOptions returns the assertion options for answering a login
+// challenge with a security key.
+func (h *LoginHandler) MFAWebAuthnOptions(c *gin.Context) {
+	var req mfaWebAuthnOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options, err := h.mfa.WebAuthnChallengeOptions(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMFAChallengeInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrWebAuthnCredentialNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// WebAuthnOptions starts a passwordless login with a discoverable
+// credential (passkey).
+func (h *LoginHandler) WebAuthnOptions(c *gin.Context) {
+	if _, ok := h.checkLoginMethod(c, c.Query("login_state"), models.LoginMethodPasskey); !ok {
+		return
+	}
+
+	options, err := h.webAuthn.BeginLogin(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// LoginWebAuthn signs the user in with a passkey alone. User verification
+// is required, so the key counts as multiple factors.
+func (h *LoginHandler) LoginWebAuthn(c *gin.Context) {
+	var req webAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := h.checkLoginMethod(c, req.LoginState, models.LoginMethodPasskey); !ok {
+		return
+	}
+
+	login, err := h.webAuthn.FinishLogin(c.Request.Context(), nil, req.Credential)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebAuthnCeremonyInvalid),
+			errors.Is(err, services.ErrWebAuthnCredentialNotFound),
+			errors.Is(err, services.ErrWebAuthnVerificationFailed),
+			errors.Is(err, services.ErrWebAuthnSignCountRegression):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	user, ok := h.signInUser(c, login.UserID)
+	if !ok {
+		return
+	}
+
+	h.completeLogin(c, user, req.LoginState, []string{login.AMR, services.AMRMFA})
+}
+
+// completeFirstFactor continues a login whose primary method succeeded:
+// users with MFA get a challenge token, everyone else a session.
+func (h *LoginHandler) completeFirstFactor(c *gin.Context, user *models.User, loginState string, amr []string) {
+	ctx := c.Request.Context()
+
+	methods, err := h.mfa.Methods(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if len(methods) == 0 {
+		h.completeLogin(c, user, loginState, amr)
+		return
+	}
+
+	token, err := h.mfa.StartChallenge(ctx, services.StartChallengeParams{
+		UserID:     user.ID,
+		LoginState: loginState,
+		AMR:        amr,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mfaRequiredResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		MFAMethods:  methods,
+	})
+}
+
+// checkLoginMethod enforces the client's configured login methods when the
+// login resumes an authorization request, which it returns (nil without a
+// login_state). Logins to the IdP itself may use any method.
+func (h *LoginHandler) checkLoginMethod(c *gin.Context, loginState string, method models.LoginMethod) (*services.AuthorizationRequest, bool) {
+	if loginState == "" {
+		return nil, true
+	}
+
+	authReq, err := h.authorizations.GetLoginState(c.Request.Context(), loginState)
+	if err != nil {
+		if errors.Is(err, services.ErrLoginStateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return nil, false
+	}
+
+	allowed := authReq.LoginMethods
+	if len(allowed) == 0 {
+		allowed = models.DefaultLoginMethods
+	}
+	if !slices.Contains(allowed, method) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errLoginMethodNotAllowed.Error()})
+		return nil, false
+	}
+
+	return authReq, true
+}
+
+// signInUser loads a user proven by a factor other than the password and
+// applies the same account status checks as a password login.
+func (h *LoginHandler) signInUser(c *gin.Context, userID uuid.UUID) (*models.User, bool) {
+	user, err := h.users.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrInvalidCredentials.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return nil, false
+	}
+
+	if err := services.CheckSignInAllowed(user); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// completeLogin starts the session for a fully authenticated user and
+// resumes the parked /authorize request, if any.
+func (h *LoginHandler) completeLogin(c *gin.Context, user *models.User, loginState string, amr []string) {
+	ctx := c.Request.Context()
+
+	var authReq *services.AuthorizationRequest
+	if loginState != "" {
+		var err error
+		authReq, err = h.authorizations.ConsumeLoginState(ctx, loginState)
+		if err != nil {
+			if errors.Is(err, services.ErrLoginStateNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+	}
+
+	session, err := h.startSession(c, user, amr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	response := loginResponse{UserID: user.ID.String(), SessionExpiresAt: session.ExpiresAt}
+
+	if authReq != nil {
+		response.RedirectTo, err = h.resumeAuthorization(c, authReq, session)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout ends the caller's IdP session. Relying parties registered for
+// front-channel logout are returned so the UI can load their logout URIs.
+func (h *LoginHandler) Logout(c *gin.Context) {
+	response := logoutResponse{Message: "logged out"}
+
+	if user, ok := currentUser(c); ok {
+		urls, err := h.logout.TerminateSession(c.Request.Context(), user.SessionID, user.ID.String())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		response.FrontchannelLogoutURIs = urls
+	}
+
+	sessions.ClearCookie(c.Writer, h.cfg.Cookie)
+	c.JSON(http.StatusOK, response)
+}
+
+// startSession signs user in on this browser. Reauthenticating the user of
+// the current session refreshes it in place, keeping its sid and the clients
+// taking part in it; a different user's session is ended first.
+func (h *LoginHandler) startSession(c *gin.Context, user *models.User, amr []string) (*sessions.Session, error) {
+	ctx := c.Request.Context()
+	now := time.Now()
+	acr := services.ACRForAMR(amr)
+
+	if previous, ok := currentUser(c); ok {
+		if previous.ID == user.ID {
+			session, err := h.sessions.Reauthenticate(ctx, previous.SessionID, sessions.ReauthenticateParams{
+				AuthTime: now,
+				AMR:      amr,
+				ACR:      acr,
+			})
+			if err == nil {
+				h.setAuthenticatedUser(c, session)
+				return session, nil
+			}
+			if !errors.Is(err, sessions.ErrSessionNotFound) {
+				return nil, err
+			}
+		} else if _, err := h.logout.TerminateSession(ctx, previous.SessionID, previous.ID.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	session, token, err := h.sessions.Create(ctx, sessions.CreateParams{
+		UserID:    user.ID,
+		AuthTime:  now,
+		AMR:       amr,
+		ACR:       acr,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions.SetCookie(c.Writer, h.cfg.Cookie, token, session)
+	h.setAuthenticatedUser(c, session)
+
+	return session, nil
+}
+
+func (h *LoginHandler) setAuthenticatedUser(c *gin.Context, session *sessions.Session) {
+	c.Set(authenticatedUserKey, &authenticatedUser{
+		ID:        session.UserID,
+		SessionID: session.ID,
+		AuthTime:  session.AuthTime,
+		AMR:       session.AMR,
+		ACR:       session.ACR,
+	})
+}
+
+// resumeAuthorization issues the code for a parked /authorize request and
+// returns the redirect the user agent should follow. A login that did not
+// reach the request's required ACR is refused with access_denied.
+func (h *LoginHandler) resumeAuthorization(c *gin.Context, authReq *services.AuthorizationRequest, session *sessions.Session) (string, error) {
+	if !services.ACRSatisfies(session.ACR, authReq.RequiredACR) {
+		params := url.Values{
+			"error":             {services.OAuthErrorAccessDenied},
+			"error_description": {"multi-factor authentication is required"},
+		}
+		if authReq.State != "" {
+			params.Set("state", authReq.State)
+		}
+		return redirectURL(authReq.RedirectURI, params)
+	}
+
+	code, err := h.authorizations.IssueCode(c.Request.Context(), authReq, services.Authentication{
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		AuthTime:  session.AuthTime,
+		AMR:       session.AMR,
+		ACR:       session.ACR,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{"code": {code}}
+	if authReq.State != "" {
+		params.Set("state", authReq.State)
+	}
+
+	return redirectURL(authReq.RedirectURI, params)
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/webauthn"
+)
+
+// MFAHandler lets signed-in users manage their second factors under /mfa and
+// admins reset them under /users/:id/mfa.
+type MFAHandler struct {
+	service  services.MFAService
+	webAuthn services.WebAuthnService
+}
+
+type enrollTOTPRequest struct {
+	Label string `json:"label"`
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type registerWebAuthnRequest struct {
+	Label      string                         `json:"label"`
+	Credential *webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+type webAuthnCredentialResponse struct {
+	ID             string   `json:"id"`
+	Label          string   `json:"label"`
+	Transports     []string `json:"transports"`
+	AAGUID         string   `json:"aaguid"`
+	BackupEligible bool     `json:"backup_eligible"`
+	BackupState    bool     `json:"backup_state"`
+	CreatedAt      string   `json:"created_at"`
+	LastUsedAt     *string  `json:"last_used_at,omitempty"`
+}
+
+type mfaFactorResponse struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	Label       string  `json:"label"`
+	Confirmed   bool    `json:"confirmed"`
+	CreatedAt   string  `json:"created_at"`
+	ConfirmedAt *string `json:"confirmed_at,omitempty"`
+	LastUsedAt  *string `json:"last_used_at,omitempty"`
+}
+
+type totpEnrollmentResponse struct {
+	FactorID        string `json:"factor_id"`
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"otpauth_uri"`
+}
+
+func NewMFAHandler(service services.MFAService, webAuthn services.WebAuthnService) *MFAHandler {
+	return &MFAHandler{service: service, webAuthn: webAuthn}
+}
+
+func (h *MFAHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/factors", h.ListOwnFactors)
+	router.DELETE("/factors/:id", h.RemoveFactor)
+	router.POST("/totp", h.EnrollTOTP)
+	router.POST("/totp/:id/confirm", h.ConfirmTOTP)
+	router.POST("/webauthn/options", h.WebAuthnRegistrationOptions)
+	router.POST("/webauthn", h.RegisterWebAuthn)
+	router.DELETE("/webauthn/:id", h.RemoveWebAuthnCredential)
+	router.POST("/backup-codes", h.RegenerateBackupCodes)
+}
+
+// RegisterUserRoutes mounts the admin endpoints on the /users group.
+func (h *MFAHandler) RegisterUserRoutes(router *gin.RouterGroup) {
+	router.GET("/:id/mfa", h.ListUserFactors)
+	router.DELETE("/:id/mfa", h.ResetUserMFA)
+}
+
+func (h *MFAHandler) ListOwnFactors(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	h.listFactors(c, user.ID)
+}
+
+// EnrollTOTP creates a pending authenticator-app factor. The secret and
+// otpauth URI are only returned here.
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	// The body is optional; it only carries a label.
+	var req enrollTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.service.EnrollTOTP(c.Request.Context(), user.ID, req.Label)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, totpEnrollmentResponse{
+		FactorID:        enrollment.FactorID.String(),
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+// ConfirmTOTP activates a pending factor. Backup codes are included when
+// this is the user's first factor.
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	factorID, ok := factorIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req confirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(c.Request.Context(), user.ID, factorID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMFAFactorNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMFAFactorConfirmed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	response := gin.H{"confirmed": true}
+	if len(codes) > 0 {
+		response["backup_codes"] = codes
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *MFAHandler) RemoveFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	factorID, ok := factorIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveFactor(c.Request.Context(), user.ID, factorID); err != nil {
+		if errors.Is(err, services.ErrMFAFactorNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// WebAuthnRegistrationOptions returns the creation options for registering
+// a security key or passkey.
+func (h *MFAHandler) WebAuthnRegistrationOptions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	options, err := h.webAuthn.BeginRegistration(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// RegisterWebAuthn stores the credential created from the registration
+// options. Backup codes are included when this is the user's first factor.
+func (h *MFAHandler) RegisterWebAuthn(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	var req registerWebAuthnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, codes, err := h.service.RegisterWebAuthn(c.Request.Context(), user.ID, req.Label, req.Credential)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebAuthnCeremonyInvalid), errors.Is(err, services.ErrWebAuthnVerificationFailed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrWebAuthnCredentialExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	response := gin.H{"credential": toWebAuthnCredentialResponse(credential)}
+	if len(codes) > 0 {
+		response["backup_codes"] = codes
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *MFAHandler) RemoveWebAuthnCredential(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	credentialID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
+		return
+	}
+
+	if err := h.service.RemoveWebAuthnCredential(c.Request.Context(), user.ID, credentialID); err != nil {
+		if errors.Is(err, services.ErrWebAuthnCredentialNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateBackupCodes replaces the user's backup codes; the old ones stop
+// working immediately.
+func (h *MFAHandler) RegenerateBackupCodes(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	codes, err := h.service.RegenerateBackupCodes(c.Request.Context(), user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrMFANotEnrolled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backup_codes": codes})
+}
+
+func (h *MFAHandler) ListUserFactors(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	h.listFactors(c, userID)
+}
+
+// ResetUserMFA removes all factors and backup codes so a user who lost their
+// device can sign in with their password and enroll again.
+func (h *MFAHandler) ResetUserMFA(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Reset(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) listFactors(c *gin.Context, userID uuid.UUID) {
+	ctx := c.Request.Context()
+
+	factors, err := h.service.ListFactors(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	credentials, err := h.webAuthn.ListCredentials(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	remaining, err := h.service.BackupCodesRemaining(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	factorResponses := make([]mfaFactorResponse, 0, len(factors))
+	for i := range factors {
+		factorResponses = append(factorResponses, toMFAFactorResponse(&factors[i]))
+	}
+
+	credentialResponses := make([]webAuthnCredentialResponse, 0, len(credentials))
+	for i := range credentials {
+		credentialResponses = append(credentialResponses, toWebAuthnCredentialResponse(&credentials[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"factors":                factorResponses,
+		"webauthn_credentials":   credentialResponses,
+		"backup_codes_remaining": remaining,
+	})
+}
+
+func factorIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid factor id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func toMFAFactorResponse(factor *models.MFAFactor) mfaFactorResponse {
+	response := mfaFactorResponse{
+		ID:        factor.ID.String(),
+		Type:      string(factor.Type),
+		Label:     factor.Label,
+		Confirmed: factor.ConfirmedAt != nil,
+		CreatedAt: factor.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if factor.ConfirmedAt != nil {
+		confirmedAt := factor.ConfirmedAt.UTC().Format(time.RFC3339)
+		response.ConfirmedAt = &confirmedAt
+	}
+	if factor.LastUsedAt != nil {
+		lastUsedAt := factor.LastUsedAt.UTC().Format(time.RFC3339)
+		response.LastUsedAt = &lastUsedAt
+	}
+	return response
+}
+
+func toWebAuthnCredentialResponse(credential *models.WebAuthnCredential) webAuthnCredentialResponse {
+	response := webAuthnCredentialResponse{
+		ID:             credential.ID.String(),
+		Label:          credential.Label,
+		Transports:     credential.Transports,
+		AAGUID:         credential.AAGUID.String(),
+		BackupEligible: credential.BackupEligible,
+		BackupState:    credential.BackupState,
+		CreatedAt:      credential.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if credential.LastUsedAt != nil {
+		lastUsedAt := credential.LastUsedAt.UTC().Format(time.RFC3339)
+		response.LastUsedAt = &lastUsedAt
+	}
+	return response
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+var errMultipleClientAuth = errors.New("client must use exactly one authentication method")
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func writeOAuthError(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, oauthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// writeTokenEndpointError renders err using RFC 6749 section 5.2 semantics.
+func writeTokenEndpointError(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		writeOAuthError(c, http.StatusInternalServerError, services.OAuthErrorServerError, "")
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuthErrorInvalidClient {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="passport"`)
+	}
+
+	writeOAuthError(c, status, oauthErr.Code, oauthErr.Description)
+}
+
+// authenticateClient authenticates the calling client, reporting failures as
+// OAuth errors ready for writeTokenEndpointError.
+func authenticateClient(c *gin.Context, clients services.ClientService) (*models.Client, error) {
+	creds, err := clientCredentialsFromRequest(c)
+	if err != nil {
+		return nil, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: err.Error()}
+	}
+
+	client, err := clients.AuthenticateClient(c.Request.Context(), creds)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidClientCredentials) {
+			return nil, &services.OAuthError{Code: services.OAuthErrorInvalidClient, Description: err.Error()}
+		}
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// clientCredentialsFromRequest extracts client_secret_basic or
+// client_secret_post credentials, falling back to a bare client_id for public
+// clients.
+func clientCredentialsFromRequest(c *gin.Context) (services.ClientCredentials, error) {
+	formID := c.PostForm("client_id")
+	formSecret := c.PostForm("client_secret")
+
+	if username, password, ok := c.Request.BasicAuth(); ok {
+		clientID, err := url.QueryUnescape(username)
+		if err != nil {
+			return services.ClientCredentials{}, err
+		}
+		secret, err := url.QueryUnescape(password)
+		if err != nil {
+			return services.ClientCredentials{}, err
+		}
+		if formSecret != "" || (formID != "" && formID != clientID) {
+			return services.ClientCredentials{}, errMultipleClientAuth
+		}
+
+		return services.ClientCredentials{
+			ClientID: clientID,
+			Secret:   secret,
+			Method:   models.ClientAuthMethodSecretBasic,
+		}, nil
+	}
+
+	if formSecret != "" {
+		return services.ClientCredentials{
+			ClientID: formID,
+			Secret:   formSecret,
+			Method:   models.ClientAuthMethodSecretPost,
+		}, nil
+	}
+
+	return services.ClientCredentials{ClientID: formID, Method: models.ClientAuthMethodNone}, nil
+}
+
+// redirectWithError reports err to the client's redirect_uri, echoing state as
+// required by RFC 6749 section 4.1.2.1.
+func redirectWithError(c *gin.Context, req *services.AuthorizationRequest, err *services.OAuthError) {
+	params := url.Values{"error": {err.Code}}
+	if err.Description != "" {
+		params.Set("error_description", err.Description)
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	redirectWithParams(c, req.RedirectURI, params)
+}
+
+func redirectWithParams(c *gin.Context, redirectURI string, params url.Values) {
+	target, err := redirectURL(redirectURI, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, target)
+}
+
+// redirectURL merges params into the query of redirectURI, keeping any query
+// the client registered.
+func redirectURL(redirectURI string, params url.Values) (string, error) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	return target.String(), nil
+}
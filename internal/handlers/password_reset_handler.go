@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/sessions"
+)
+
+var passwordResetFormTemplate = template.Must(template.New("password_reset").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Reset your password</title>
+</head>
+<body>
+<form id="reset" method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>New password <input type="password" name="password" autocomplete="new-password" minlength="8" required></label>
+<button type="submit">Reset password</button>
+</form>
+<p id="result"></p>
+<script>
+(function () {
+  var form = document.getElementById("reset");
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    fetch(form.action, { method: "POST", body: new URLSearchParams(new FormData(form)) })
+      .then(function (response) { return response.json(); })
+      .then(function (result) {
+        document.getElementById("result").textContent = result.message || result.error;
+        if (result.message) { form.remove(); }
+      });
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+type passwordResetForm struct {
+	Action string
+	Token  string
+}
+
+type PasswordResetHandler struct {
+	service services.PasswordResetService
+	cfg     config.SSOConfig
+}
+
+type forgotPasswordRequest struct {
+	Email    string `json:"email" form:"email" binding:"required"`
+	ClientID string `json:"client_id" form:"client_id"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" form:"token" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+func NewPasswordResetHandler(service services.PasswordResetService, cfg config.SSOConfig) *PasswordResetHandler {
+	return &PasswordResetHandler{service: service, cfg: cfg}
+}
+
+func (h *PasswordResetHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/password/forgot", h.Forgot)
+	router.GET(services.PasswordResetPath, h.ResetForm)
+	router.POST(services.PasswordResetPath, h.Reset)
+}
+
+// Forgot answers 202 whatever happens to the address, including internal
+// failures, so the endpoint cannot be used to enumerate accounts.
+func (h *PasswordResetHandler) Forgot(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.RequestReset(c.Request.Context(), req.Email, req.ClientID); err != nil {
+		log.Printf("failed to process password reset request: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists, a reset link is on its way"})
+}
+
+// ResetForm is where emailed reset links land. It only renders a form that
+// carries the token; the token is redeemed when the form is posted, so mail
+// scanners following the link cannot use it up.
+func (h *PasswordResetHandler) ResetForm(c *gin.Context) {
+	var body bytes.Buffer
+	if err := passwordResetFormTemplate.Execute(&body, passwordResetForm{
+		Action: services.PasswordResetPath,
+		Token:  c.Query("token"),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
+
+func (h *PasswordResetHandler) Reset(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasswordResetTokenInvalid), errors.Is(err, services.ErrInvalidPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	// Every session was revoked, including this browser's.
+	sessions.ClearCookie(c.Writer, h.cfg.Cookie)
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type RevocationHandler struct {
+	clients    services.ClientService
+	revocation services.RevocationService
+}
+
+type revocationRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+func NewRevocationHandler(clients services.ClientService, revocation services.RevocationService) *RevocationHandler {
+	return &RevocationHandler{clients: clients, revocation: revocation}
+}
+
+func (h *RevocationHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/revoke", h.Revoke)
+}
+
+func (h *RevocationHandler) Revoke(c *gin.Context) {
+	var req revocationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+		return
+	}
+
+	client, err := authenticateClient(c, h.clients)
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	if err := h.revocation.Revoke(c.Request.Context(), client, req.Token, req.TokenTypeHint); err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
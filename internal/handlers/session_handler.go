@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/sessions"
+)
+
+// SessionHandler serves the session dashboard: users manage their own
+// sessions under /sessions, admins any user's under /users/:id/sessions.
+type SessionHandler struct {
+	store  sessions.Store
+	logout services.LogoutService
+	cfg    config.SSOConfig
+}
+
+type sessionResponse struct {
+	ID         string          `json:"id"`
+	Current    bool            `json:"current"`
+	Device     sessions.Device `json:"device"`
+	UserAgent  string          `json:"user_agent"`
+	IPAddress  string          `json:"ip_address"`
+	AMR        []string        `json:"amr"`
+	Clients    []string        `json:"clients"`
+	AuthTime   string          `json:"auth_time"`
+	CreatedAt  string          `json:"created_at"`
+	LastSeenAt string          `json:"last_seen_at"`
+	ExpiresAt  string          `json:"expires_at"`
+}
+
+func NewSessionHandler(store sessions.Store, logout services.LogoutService, cfg config.SSOConfig) *SessionHandler {
+	return &SessionHandler{store: store, logout: logout, cfg: cfg}
+}
+
+func (h *SessionHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("", h.ListOwnSessions)
+	router.DELETE("", h.RevokeOtherSessions)
+	router.DELETE("/:sid", h.RevokeOwnSession)
+}
+
+// RegisterUserRoutes mounts the admin endpoints on the /users group.
+func (h *SessionHandler) RegisterUserRoutes(router *gin.RouterGroup) {
+	router.GET("/:id/sessions", h.ListUserSessions)
+	router.DELETE("/:id/sessions", h.RevokeUserSessions)
+	router.DELETE("/:id/sessions/:sid", h.RevokeUserSession)
+}
+
+func (h *SessionHandler) ListOwnSessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	h.listSessions(c, user.ID, user.SessionID)
+}
+
+// RevokeOtherSessions signs the user out everywhere except this browser.
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	h.revokeSessions(c, user.ID, user.SessionID)
+}
+
+func (h *SessionHandler) RevokeOwnSession(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.OAuthErrorLoginRequired})
+		return
+	}
+
+	sessionID := c.Param("sid")
+	if !h.revokeSession(c, user.ID, sessionID) {
+		return
+	}
+
+	if sessionID == user.SessionID {
+		sessions.ClearCookie(c.Writer, h.cfg.Cookie)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SessionHandler) ListUserSessions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	h.listSessions(c, userID, "")
+}
+
+func (h *SessionHandler) RevokeUserSessions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	h.revokeSessions(c, userID, "")
+}
+
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if h.revokeSession(c, userID, c.Param("sid")) {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *SessionHandler) listSessions(c *gin.Context, userID uuid.UUID, currentSessionID string) {
+	userSessions, err := h.store.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	responses := make([]sessionResponse, 0, len(userSessions))
+	for i := range userSessions {
+		responses = append(responses, toSessionResponse(&userSessions[i], currentSessionID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": responses})
+}
+
+// revokeSessions revokes every session of userID except keepSessionID.
+func (h *SessionHandler) revokeSessions(c *gin.Context, userID uuid.UUID, keepSessionID string) {
+	userSessions, err := h.store.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	revoked := 0
+	for _, session := range userSessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := h.logout.RevokeSession(c.Request.Context(), session.ID, userID.String()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		revoked++
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// revokeSession revokes sessionID if it belongs to userID, writing the error
+// response otherwise.
+func (h *SessionHandler) revokeSession(c *gin.Context, userID uuid.UUID, sessionID string) bool {
+	session, err := h.store.Get(c.Request.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, sessions.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return false
+	}
+
+	// Someone else's session is reported exactly like a missing one.
+	if session.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return false
+	}
+
+	if err := h.logout.RevokeSession(c.Request.Context(), session.ID, userID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return false
+	}
+
+	return true
+}
+
+func userIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func toSessionResponse(session *sessions.Session, currentSessionID string) sessionResponse {
+	amr := session.AMR
+	if amr == nil {
+		amr = []string{}
+	}
+	clients := session.Clients
+	if clients == nil {
+		clients = []string{}
+	}
+
+	return sessionResponse{
+		ID:         session.ID,
+		Current:    currentSessionID != "" && session.ID == currentSessionID,
+		Device:     sessions.DescribeUserAgent(session.UserAgent),
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		AMR:        amr,
+		Clients:    clients,
+		AuthTime:   session.AuthTime.UTC().Format(time.RFC3339),
+		CreatedAt:  session.CreatedAt.UTC().Format(time.RFC3339),
+		LastSeenAt: session.LastSeenAt.UTC().Format(time.RFC3339),
+		ExpiresAt:  session.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
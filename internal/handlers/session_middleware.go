@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/sessions"
+)
+
+// LoadSession binds the user behind a valid IdP session cookie to the
+// request. Unknown or expired cookies are cleared and the request continues
+// anonymously.
+func LoadSession(store sessions.Store, cookie config.CookieConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := sessions.CookieToken(c.Request, cookie)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		session, err := store.Resolve(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, sessions.ErrSessionNotFound) {
+				sessions.ClearCookie(c.Writer, cookie)
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+
+		c.Set(authenticatedUserKey, &authenticatedUser{
+			ID:        session.UserID,
+			SessionID: session.ID,
+			AuthTime:  session.AuthTime,
+			AMR:       session.AMR,
+			ACR:       session.ACR,
+		})
+		c.Next()
+	}
+}
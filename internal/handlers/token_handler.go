@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type TokenHandler struct {
+	clients services.ClientService
+	tokens  services.TokenService
+}
+
+type tokenRequest struct {
+	GrantType    string   `form:"grant_type"`
+	Code         string   `form:"code"`
+	RedirectURI  string   `form:"redirect_uri"`
+	CodeVerifier string   `form:"code_verifier"`
+	RefreshToken string   `form:"refresh_token"`
+	Scope        string   `form:"scope"`
+	Audience     string   `form:"audience"`
+	Resource     []string `form:"resource"`
+	DeviceCode   string   `form:"device_code"`
+}
+
+func NewTokenHandler(clients services.ClientService, tokens services.TokenService) *TokenHandler {
+	return &TokenHandler{clients: clients, tokens: tokens}
+}
+
+func (h *TokenHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/token", h.Token)
+}
+
+func (h *TokenHandler) Token(c *gin.Context) {
+	var req tokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, err.Error())
+		return
+	}
+
+	if req.GrantType == "" {
+		writeOAuthError(c, http.StatusBadRequest, services.OAuthErrorInvalidRequest, "grant_type is required")
+		return
+	}
+
+	client, err := authenticateClient(c, h.clients)
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	var response *services.TokenResponse
+	switch req.GrantType {
+	case services.GrantTypeAuthorizationCode:
+		response, err = h.tokens.ExchangeAuthorizationCode(c.Request.Context(), client, services.AuthorizationCodeGrant{
+			Code:         req.Code,
+			RedirectURI:  req.RedirectURI,
+			CodeVerifier: req.CodeVerifier,
+		})
+	case services.GrantTypeRefreshToken:
+		response, err = h.tokens.ExchangeRefreshToken(c.Request.Context(), client, services.RefreshTokenGrant{
+			RefreshToken: req.RefreshToken,
+			Scope:        req.Scope,
+		})
+	case services.GrantTypeClientCredentials:
+		response, err = h.tokens.ExchangeClientCredentials(c.Request.Context(), client, services.ClientCredentialsGrant{
+			Scope:     req.Scope,
+			Audience:  req.Audience,
+			Resources: req.Resource,
+		})
+	case services.GrantTypeDeviceCode:
+		response, err = h.tokens.ExchangeDeviceCode(c.Request.Context(), client, req.DeviceCode)
+	default:
+		err = &services.OAuthError{Code: services.OAuthErrorUnsupportedGrantType}
+	}
+	if err != nil {
+		writeTokenEndpointError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, response)
+}
@@ -19,21 +19,26 @@ type UserHandler struct {
 }
 
 type createUserRequest struct {
-	Email         string   `json:"email" binding:"required,email"`
-	Password      string   `json:"password" binding:"required,min=8"`
-	MFAFactors    []string `json:"mfa_factors"`
-	Status        string   `json:"status"`
-	EmailVerified bool     `json:"email_verified"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=8"`
+	Name       string `json:"name"`
+	GivenName  string `json:"given_name"`
+	FamilyName string `json:"family_name"`
+	Locale     string `json:"locale"`
+	ClientID   string `json:"client_id"`
 }
 
 type userResponse struct {
-	ID            string   `json:"id"`
-	Email         string   `json:"email"`
-	EmailVerified bool     `json:"email_verified"`
-	MFAFactors    []string `json:"mfa_factors"`
-	Status        string   `json:"status"`
-	CreatedAt     string   `json:"created_at"`
-	UpdatedAt     string   `json:"updated_at"`
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Locale        string `json:"locale"`
+	Status        string `json:"status"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
 }
 
 func NewUserHandler(service services.UserService) *UserHandler {
@@ -54,20 +59,19 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	}
 
 	params := services.CreateUserParams{
-		Email:         req.Email,
-		Password:      req.Password,
-		MFAFactors:    req.MFAFactors,
-		EmailVerified: req.EmailVerified,
-	}
-
-	if req.Status != "" {
-		params.Status = models.UserStatus(req.Status)
+		Email:      req.Email,
+		Password:   req.Password,
+		Name:       req.Name,
+		GivenName:  req.GivenName,
+		FamilyName: req.FamilyName,
+		Locale:     req.Locale,
+		ClientID:   req.ClientID,
 	}
 
 	user, err := h.service.CreateUser(c.Request.Context(), params)
 	if err != nil {
 		switch {
-		case errors.Is(err, services.ErrInvalidPassword), errors.Is(err, services.ErrInvalidUserStatus):
+		case errors.Is(err, services.ErrInvalidPassword):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		case errors.Is(err, repositories.ErrUserAlreadyExists):
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
@@ -138,16 +142,14 @@ func parseQueryInt(c *gin.Context, key string) (int, error) {
 }
 
 func toUserResponse(user *models.User) userResponse {
-	factors := user.MFAFactors
-	if factors == nil {
-		factors = []string{}
-	}
-
 	return userResponse{
 		ID:            user.ID.String(),
 		Email:         user.Email,
 		EmailVerified: user.EmailVerified,
-		MFAFactors:    factors,
+		Name:          user.Name,
+		GivenName:     user.GivenName,
+		FamilyName:    user.FamilyName,
+		Locale:        user.Locale,
 		Status:        string(user.Status),
 		CreatedAt:     user.CreatedAt.UTC().Format(time.RFC3339),
 		UpdatedAt:     user.UpdatedAt.UTC().Format(time.RFC3339),
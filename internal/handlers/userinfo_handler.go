@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/services"
+)
+
+type UserInfoHandler struct {
+	users  services.UserService
+	tokens services.TokenService
+}
+
+func NewUserInfoHandler(users services.UserService, tokens services.TokenService) *UserInfoHandler {
+	return &UserInfoHandler{users: users, tokens: tokens}
+}
+
+func (h *UserInfoHandler) RegisterRoutes(router *gin.RouterGroup) {
+	requireToken := RequireAccessToken(h.tokens)
+
+	router.GET("/userinfo", requireToken, h.GetUserInfo)
+	router.POST("/userinfo", requireToken, h.GetUserInfo)
+}
+
+func (h *UserInfoHandler) GetUserInfo(c *gin.Context) {
+	claims, ok := accessTokenClaims(c)
+	if !ok {
+		writeBearerError(c, http.StatusUnauthorized, "invalid_token", services.ErrInvalidAccessToken.Error())
+		return
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, services.ScopeOpenID) {
+		writeBearerError(c, http.StatusForbidden, "insufficient_scope", "the openid scope is required")
+		return
+	}
+
+	// Client credentials tokens are issued to the client itself, so their
+	// subject equals client_id. Client IDs are UUIDs as well, so parsing the
+	// subject alone cannot tell them apart from user tokens.
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil || claims.Subject == claims.ClientID {
+		writeBearerError(c, http.StatusUnauthorized, "invalid_token", "token subject is not a user")
+		return
+	}
+
+	user, err := h.users.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			writeBearerError(c, http.StatusUnauthorized, "invalid_token", "token subject is not a user")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if user.Status == models.UserStatusDisabled {
+		writeBearerError(c, http.StatusUnauthorized, "invalid_token", "user is disabled")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, services.UserInfo{
+		Subject:    user.ID.String(),
+		UserClaims: services.ReleaseUserClaims(user, scopes),
+	})
+}
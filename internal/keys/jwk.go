@@ -0,0 +1,108 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK is the public half of a signing key as described by RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (m *manager) JWKS(ctx context.Context) (*JWKS, error) {
+	// Make sure there is always something to publish, even before the first
+	// token has been signed.
+	if _, err := m.activeKey(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	records, err := m.repo.ListPublishable(ctx, now.Add(-m.retention))
+	if err != nil {
+		return nil, err
+	}
+
+	set := &JWKS{Keys: make([]JWK, 0, len(records))}
+	for i := range records {
+		key, err := m.parse(&records[i])
+		if err != nil {
+			return nil, err
+		}
+
+		jwk, err := publicJWK(key.public)
+		if err != nil {
+			return nil, err
+		}
+
+		jwk.Use = "sig"
+		jwk.Algorithm = string(key.record.Algorithm)
+		jwk.KeyID = key.record.KID
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+func publicJWK(public crypto.PublicKey) (JWK, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType: "RSA",
+			N:       encodeSegment(key.N.Bytes()),
+			E:       encodeSegment(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			KeyType: "EC",
+			Curve:   key.Curve.Params().Name,
+			X:       encodeSegment(key.X.FillBytes(make([]byte, size))),
+			Y:       encodeSegment(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	default:
+		return JWK{}, ErrUnsupportedAlgorithm
+	}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as the key ID.
+func thumbprint(public crypto.PublicKey) (string, error) {
+	jwk, err := publicJWK(public)
+	if err != nil {
+		return "", err
+	}
+
+	var canonical string
+	switch jwk.KeyType {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.KeyType, jwk.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Curve, jwk.KeyType, jwk.X, jwk.Y)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func encodeSegment(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+const es256SignatureSize = 64
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownKey   = errors.New("token signed by unknown key")
+)
+
+type jwsHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Sign serializes claims as a compact JWS signed with the active key.
+func (m *manager) Sign(ctx context.Context, claims any) (string, error) {
+	key, err := m.activeKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(jwsHeader{
+		Algorithm: string(key.record.Algorithm),
+		KeyID:     key.record.KID,
+		Type:      "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(header) + "." + encodeSegment(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch private := key.private.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, private, digest[:])
+		if err == nil {
+			signature = make([]byte, es256SignatureSize)
+			r.FillBytes(signature[:es256SignatureSize/2])
+			s.FillBytes(signature[es256SignatureSize/2:])
+		}
+	default:
+		err = ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// Verify checks the signature of a compact JWS against the published keys and
+// decodes its payload into claims. Claim validation is left to the caller.
+func (m *manager) Verify(ctx context.Context, token string, claims any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	var header jwsHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil || header.KeyID == "" {
+		return ErrInvalidToken
+	}
+
+	key, err := m.keyByID(ctx, header.KeyID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrSigningKeyNotFound) {
+			return ErrUnknownKey
+		}
+		return err
+	}
+
+	if header.Algorithm != string(key.record.Algorithm) || !m.isVerifiable(key, time.Now().UTC()) {
+		return ErrUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidToken
+		}
+	case *ecdsa.PublicKey:
+		if len(signature) != es256SignatureSize {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(signature[:es256SignatureSize/2])
+		s := new(big.Int).SetBytes(signature[es256SignatureSize/2:])
+		if !ecdsa.Verify(public, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+const (
+	rsaKeyBits = 2048
+
+	// keyStateTTL bounds how long a cached key's state is trusted before it
+	// is re-read, so keys retired or removed by another instance stop
+	// verifying tokens here too.
+	keyStateTTL = time.Minute
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidKeyMaterial   = errors.New("invalid signing key material")
+)
+
+// Manager owns the signing keys used for tokens issued by the server. Active
+// keys are generated lazily the first time an algorithm is needed.
+type Manager interface {
+	Algorithm() models.SigningAlgorithm
+	Rotate(ctx context.Context, algorithm models.SigningAlgorithm) (*models.SigningKey, error)
+	JWKS(ctx context.Context) (*JWKS, error)
+	Sign(ctx context.Context, claims any) (string, error)
+	Verify(ctx context.Context, token string, claims any) error
+}
+
+// parsedKey is never modified once cached; a newer state of the same key
+// replaces the cache entry instead, so readers need no lock.
+type parsedKey struct {
+	record   models.SigningKey
+	private  crypto.Signer
+	public   crypto.PublicKey
+	loadedAt time.Time
+}
+
+type manager struct {
+	repo      repositories.SigningKeyRepository
+	algorithm models.SigningAlgorithm
+	retention time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*parsedKey
+}
+
+// NewManager returns a Manager that signs with algorithm and keeps retired keys
+// published for retention so tokens they signed remain verifiable.
+func NewManager(repo repositories.SigningKeyRepository, algorithm models.SigningAlgorithm, retention time.Duration) Manager {
+	return &manager{
+		repo:      repo,
+		algorithm: algorithm,
+		retention: retention,
+		cache:     make(map[string]*parsedKey),
+	}
+}
+
+func (m *manager) Algorithm() models.SigningAlgorithm {
+	return m.algorithm
+}
+
+func (m *manager) Rotate(ctx context.Context, algorithm models.SigningAlgorithm) (*models.SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, err := m.repo.GetActive(ctx, algorithm)
+	if err != nil && !errors.Is(err, repositories.ErrSigningKeyNotFound) {
+		return nil, err
+	}
+
+	key, err := m.createKey(ctx, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		now := time.Now().UTC()
+		if err := m.repo.Retire(ctx, previous.ID, now); err != nil {
+			return nil, err
+		}
+		if cached, ok := m.cache[previous.KID]; ok {
+			retired := *cached
+			retired.record.State = models.SigningKeyStateRetired
+			retired.record.RetiredAt = &now
+			m.cache[previous.KID] = &retired
+		}
+	}
+
+	record := key.record
+	return &record, nil
+}
+
+func (m *manager) activeKey(ctx context.Context) (*parsedKey, error) {
+	record, err := m.repo.GetActive(ctx, m.algorithm)
+	if err == nil {
+		return m.parse(record)
+	}
+	if !errors.Is(err, repositories.ErrSigningKeyNotFound) {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Another request may have generated the key while we waited for the lock.
+	record, err = m.repo.GetActive(ctx, m.algorithm)
+	if err == nil {
+		return m.parseLocked(record)
+	}
+	if !errors.Is(err, repositories.ErrSigningKeyNotFound) {
+		return nil, err
+	}
+
+	return m.createKey(ctx, m.algorithm)
+}
+
+func (m *manager) keyByID(ctx context.Context, kid string) (*parsedKey, error) {
+	m.mu.Lock()
+	cached, ok := m.cache[kid]
+	m.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < keyStateTTL {
+		return cached, nil
+	}
+
+	record, err := m.repo.GetByKID(ctx, kid)
+	if err != nil {
+		if errors.Is(err, repositories.ErrSigningKeyNotFound) {
+			m.mu.Lock()
+			delete(m.cache, kid)
+			m.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	return m.parse(record)
+}
+
+// createKey must be called with m.mu held.
+func (m *manager) createKey(ctx context.Context, algorithm models.SigningAlgorithm) (*parsedKey, error) {
+	record, err := Generate(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return m.parseLocked(record)
+}
+
+func (m *manager) parse(record *models.SigningKey) (*parsedKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.parseLocked(record)
+}
+
+func (m *manager) parseLocked(record *models.SigningKey) (*parsedKey, error) {
+	var private crypto.Signer
+	if cached, ok := m.cache[record.KID]; ok {
+		private = cached.private
+	} else {
+		var err error
+		if private, err = decodePrivateKey(record.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+
+	key := &parsedKey{record: *record, private: private, public: private.Public(), loadedAt: time.Now()}
+	m.cache[record.KID] = key
+
+	return key, nil
+}
+
+func (m *manager) isVerifiable(key *parsedKey, now time.Time) bool {
+	if key.record.State == models.SigningKeyStateActive {
+		return true
+	}
+	return key.record.RetiredAt != nil && key.record.RetiredAt.Add(m.retention).After(now)
+}
+
+// Generate creates a new keypair for algorithm, identified by its RFC 7638
+// thumbprint. The returned record is ready to be persisted.
+func Generate(algorithm models.SigningAlgorithm) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case models.SigningAlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case models.SigningAlgorithmES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := thumbprint(private.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SigningKey{
+		ID:          uuid.New(),
+		KID:         kid,
+		Algorithm:   algorithm,
+		State:       models.SigningKeyStateActive,
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		PublicKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		ActivatedAt: time.Now().UTC(),
+	}, nil
+}
+
+func decodePrivateKey(encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, ErrInvalidKeyMaterial
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, ErrInvalidKeyMaterial
+	}
+
+	return signer, nil
+}
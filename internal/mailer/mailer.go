@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+)
+
+var ErrNoRecipients = errors.New("message has no recipients")
+
+// Message is a transactional email. HTML is optional; when set the message is
+// sent as multipart/alternative with Text as the fallback part.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers transactional email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// IsPermanent reports whether retrying err cannot succeed: the message is
+// malformed or the server rejected it with a 5xx reply.
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrNoRecipients) {
+		return true
+	}
+
+	var protocolErr *textproto.Error
+	return errors.As(err, &protocolErr) && protocolErr.Code >= 500
+}
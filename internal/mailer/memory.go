@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer records messages instead of sending them. It is meant for
+// tests and local tooling.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(_ context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg.To = append([]string(nil), msg.To...)
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
+
+// Last returns the most recent message sent to address.
+func (m *MemoryMailer) Last(address string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		for _, to := range m.messages[i].To {
+			if to == address {
+				return m.messages[i], true
+			}
+		}
+	}
+	return Message{}, false
+}
+
+// Reset forgets all recorded messages.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMemoryMailerRecordsVerificationEmail(t *testing.T) {
+	templates := loadTestTemplates(t)
+	mailer := NewMemoryMailer()
+
+	link := "https://id.example.com/verify-email?token=abc.def.ghi"
+	msg, err := templates.Render(TemplateVerifyEmail, "en", DefaultBranding, map[string]any{"Name": "Jane", "Link": link})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	msg.To = []string{"jane@example.com"}
+
+	if err := mailer.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sent, ok := mailer.Last("jane@example.com")
+	if !ok {
+		t.Fatal("no message recorded for jane@example.com")
+	}
+
+	var found string
+	for _, field := range strings.Fields(sent.Text) {
+		if strings.HasPrefix(field, "https://") {
+			found = field
+		}
+	}
+	parsed, err := url.Parse(found)
+	if err != nil || parsed.Path != "/verify-email" || parsed.Query().Get("token") != "abc.def.ghi" {
+		t.Fatalf("verification link = %q, want %q", found, link)
+	}
+
+	if _, ok := mailer.Last("someone@example.com"); ok {
+		t.Error("Last found a message for an address that received none")
+	}
+}
+
+func TestMemoryMailerRejectsMessageWithoutRecipients(t *testing.T) {
+	mailer := NewMemoryMailer()
+
+	if err := mailer.Send(context.Background(), Message{Subject: "hi", Text: "hi"}); !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("Send error = %v, want %v", err, ErrNoRecipients)
+	}
+	if len(mailer.Messages()) != 0 {
+		t.Fatal("message without recipients was recorded")
+	}
+}
+
+func TestMemoryMailerCopiesAndResets(t *testing.T) {
+	mailer := NewMemoryMailer()
+
+	recipients := []string{"jane@example.com"}
+	if err := mailer.Send(context.Background(), Message{To: recipients, Subject: "hi", Text: "hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	recipients[0] = "changed@example.com"
+
+	if _, ok := mailer.Last("jane@example.com"); !ok {
+		t.Fatal("recorded message changed with the caller's slice")
+	}
+
+	mailer.Reset()
+	if len(mailer.Messages()) != 0 {
+		t.Fatal("Reset kept messages")
+	}
+}
@@ -0,0 +1,314 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// TemplateName identifies a transactional email.
+type TemplateName string
+
+const (
+	TemplateVerifyEmail   TemplateName = "verify_email"
+	TemplatePasswordReset TemplateName = "password_reset"
+	TemplateMFAChanged    TemplateName = "mfa_changed"
+	TemplateSecurityAlert TemplateName = "security_alert"
+	TemplateLoginLink     TemplateName = "login_link"
+	TemplateLoginCode     TemplateName = "login_code"
+
+	DefaultLocale = "en"
+)
+
+var (
+	// TemplateNames lists every template the registry must provide.
+	TemplateNames = []TemplateName{
+		TemplateVerifyEmail,
+		TemplatePasswordReset,
+		TemplateMFAChanged,
+		TemplateSecurityAlert,
+		TemplateLoginLink,
+		TemplateLoginCode,
+	}
+
+	SupportedLocales = []string{"en", "de", "fa"}
+
+	rightToLeftLocales = map[string]bool{"fa": true}
+
+	// DefaultBranding is used when an email is not sent on behalf of a client.
+	DefaultBranding = Branding{Name: "Passport"}
+
+	ErrUnknownTemplate = errors.New("unknown email template")
+
+	// verbatimKeys are data values every locale must show as-is; Validate
+	// checks for them in both bodies.
+	verbatimKeys = []string{"Name", "Link", "Code", "IPAddress"}
+
+	// secretKeys are data values that must stay out of subjects, which are
+	// shown in notifications and on lock screens.
+	secretKeys = []string{"Link", "Code"}
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// Branding is the per-client look of an email.
+type Branding struct {
+	Name    string
+	LogoURL string
+}
+
+// TemplateData is what templates are executed with. Data holds the
+// template-specific values; a missing key is a render error.
+type TemplateData struct {
+	Locale  string
+	Dir     string
+	Align   string
+	Subject string
+	Brand   Branding
+	Data    map[string]any
+}
+
+type templateKey struct {
+	name   TemplateName
+	locale string
+}
+
+// Templates is the registry of parsed email templates. Each template has a
+// text file defining "subject" and "text", and an HTML file defining
+// "content" that is wrapped in the shared layout.
+type Templates struct {
+	text map[templateKey]*texttemplate.Template
+	html map[templateKey]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates, letting any file with the same
+// relative path under overrideDir take precedence, and validates the result.
+func LoadTemplates(overrideDir string) (*Templates, error) {
+	source, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(name string) ([]byte, error) {
+		if overrideDir != "" {
+			content, err := os.ReadFile(filepath.Join(overrideDir, filepath.FromSlash(name)))
+			if err == nil {
+				return content, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		return fs.ReadFile(source, name)
+	}
+
+	layout, err := read("layout.html")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{
+		text: make(map[templateKey]*texttemplate.Template),
+		html: make(map[templateKey]*htmltemplate.Template),
+	}
+
+	for _, name := range TemplateNames {
+		for _, locale := range SupportedLocales {
+			key := templateKey{name: name, locale: locale}
+			base := path.Join(locale, string(name))
+
+			textSource, err := read(base + ".txt")
+			if err != nil {
+				return nil, err
+			}
+			textTemplate, err := texttemplate.New(base + ".txt").Option("missingkey=error").Parse(string(textSource))
+			if err != nil {
+				return nil, err
+			}
+
+			htmlSource, err := read(base + ".html")
+			if err != nil {
+				return nil, err
+			}
+			htmlTemplate, err := htmltemplate.New("layout.html").Option("missingkey=error").Parse(string(layout))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := htmlTemplate.New(base + ".html").Parse(string(htmlSource)); err != nil {
+				return nil, err
+			}
+
+			t.text[key] = textTemplate
+			t.html[key] = htmlTemplate
+		}
+	}
+
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Render produces the subject and bodies of name in the closest supported
+// locale. The returned message has no recipients.
+func (t *Templates) Render(name TemplateName, locale string, brand Branding, data map[string]any) (Message, error) {
+	locale = ResolveLocale(locale)
+	key := templateKey{name: name, locale: locale}
+
+	textTemplate, ok := t.text[key]
+	if !ok {
+		return Message{}, ErrUnknownTemplate
+	}
+	htmlTemplate := t.html[key]
+
+	if brand.Name == "" {
+		brand.Name = DefaultBranding.Name
+	}
+
+	values := TemplateData{
+		Locale: locale,
+		Dir:    "ltr",
+		Align:  "left",
+		Brand:  brand,
+		Data:   data,
+	}
+	if rightToLeftLocales[locale] {
+		values.Dir = "rtl"
+		values.Align = "right"
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return Message{}, err
+	}
+	values.Subject = strings.TrimSpace(subject.String())
+
+	if err := textTemplate.ExecuteTemplate(&text, "text", values); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplate.ExecuteTemplate(&html, "layout", values); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: values.Subject,
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// Validate renders every template in every locale with SampleData, with and
+// without a logo, and checks the output: a subject, both bodies, the brand
+// name, and every sample string value in the text body. Overrides that drop
+// a link or reference unknown data are caught here instead of in a user's
+// inbox.
+func (t *Templates) Validate() error {
+	var problems []error
+
+	brands := []Branding{
+		DefaultBranding,
+		{Name: "Example App", LogoURL: "https://app.example.com/logo.png"},
+	}
+
+	for _, name := range TemplateNames {
+		data := SampleData(name)
+		for _, locale := range SupportedLocales {
+			for _, brand := range brands {
+				msg, err := t.Render(name, locale, brand, data)
+				if err != nil {
+					problems = append(problems, fmt.Errorf("%s/%s: %w", locale, name, err))
+					continue
+				}
+				for _, problem := range checkRendered(msg, brand, data) {
+					problems = append(problems, fmt.Errorf("%s/%s: %s", locale, name, problem))
+				}
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+func checkRendered(msg Message, brand Branding, data map[string]any) []string {
+	var problems []string
+
+	if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+		problems = append(problems, "subject must be a single non-empty line")
+	}
+	for _, key := range secretKeys {
+		if secret, ok := data[key].(string); ok && secret != "" && strings.Contains(msg.Subject, secret) {
+			problems = append(problems, fmt.Sprintf("subject must not include %s", key))
+		}
+	}
+	if strings.TrimSpace(msg.Text) == "" {
+		problems = append(problems, "text body is empty")
+	}
+	if !strings.Contains(msg.HTML, htmltemplate.HTMLEscapeString(brand.Name)) {
+		problems = append(problems, "html body does not show the brand name")
+	}
+	if brand.LogoURL != "" && !strings.Contains(msg.HTML, brand.LogoURL) {
+		problems = append(problems, "html body does not show the brand logo")
+	}
+
+	for _, key := range verbatimKeys {
+		text, ok := data[key].(string)
+		if !ok || text == "" {
+			continue
+		}
+		if !strings.Contains(msg.Text, text) {
+			problems = append(problems, fmt.Sprintf("text body does not include %s", key))
+		}
+		if !strings.Contains(msg.HTML, htmltemplate.HTMLEscapeString(text)) {
+			problems = append(problems, fmt.Sprintf("html body does not include %s", key))
+		}
+	}
+
+	return problems
+}
+
+// SampleData returns representative values for name, used by Validate and
+// by the admin preview.
+func SampleData(name TemplateName) map[string]any {
+	switch name {
+	case TemplateVerifyEmail:
+		return map[string]any{"Name": "Jane", "Link": "https://id.example.com/verify-email?token=sample"}
+	case TemplatePasswordReset:
+		return map[string]any{"Name": "Jane", "Link": "https://id.example.com/password/reset?token=sample"}
+	case TemplateMFAChanged:
+		return map[string]any{"Name": "Jane", "Event": "enrolled", "Method": "totp"}
+	case TemplateSecurityAlert:
+		return map[string]any{"Name": "Jane", "Event": "account_locked", "IPAddress": "203.0.113.7", "Until": "2025-01-01 12:00 UTC"}
+	case TemplateLoginLink:
+		return map[string]any{"Name": "Jane", "Link": "https://id.example.com/login/email/verify?token=sample", "Minutes": 10}
+	case TemplateLoginCode:
+		return map[string]any{"Name": "Jane", "Code": "482913", "Minutes": 10}
+	default:
+		return map[string]any{}
+	}
+}
+
+// ResolveLocale maps a BCP 47 tag to a supported locale: exact match, then
+// language, then DefaultLocale.
+func ResolveLocale(locale string) string {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	language, _, _ := strings.Cut(tag, "-")
+
+	for _, candidate := range []string{tag, language} {
+		for _, supported := range SupportedLocales {
+			if candidate == supported {
+				return supported
+			}
+		}
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+)
+
+func loadTestTemplates(t *testing.T) *Templates {
+	t.Helper()
+
+	templates, err := LoadTemplates("")
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	return templates
+}
+
+func TestRenderEveryTemplateAndLocale(t *testing.T) {
+	templates := loadTestTemplates(t)
+	brand := Branding{Name: "Acme & Co", LogoURL: "https://acme.example.com/logo.png"}
+
+	for _, name := range TemplateNames {
+		data := SampleData(name)
+		for _, locale := range SupportedLocales {
+			t.Run(locale+"/"+string(name), func(t *testing.T) {
+				msg, err := templates.Render(name, locale, brand, data)
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+
+				if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+					t.Fatalf("subject = %q, want a single non-empty line", msg.Subject)
+				}
+				if !strings.Contains(msg.Subject, brand.Name) {
+					t.Errorf("subject %q does not name the brand", msg.Subject)
+				}
+				for _, key := range secretKeys {
+					if secret, ok := data[key].(string); ok && strings.Contains(msg.Subject, secret) {
+						t.Errorf("subject %q includes %s", msg.Subject, key)
+					}
+				}
+
+				if !strings.HasSuffix(msg.Text, "\n") || strings.Contains(msg.Text, "<p>") {
+					t.Errorf("text body is not plain text: %q", msg.Text)
+				}
+				if !strings.Contains(msg.Text, "Jane") {
+					t.Error("text body does not greet the user")
+				}
+
+				escapedBrand := htmltemplate.HTMLEscapeString(brand.Name)
+				if !strings.Contains(msg.HTML, `<img src="`+brand.LogoURL+`" alt="`+escapedBrand+`"`) {
+					t.Error("html body does not show the brand logo")
+				}
+				if !strings.Contains(msg.HTML, `lang="`+locale+`"`) {
+					t.Errorf("html body is not tagged with locale %q", locale)
+				}
+				if !strings.Contains(msg.HTML, "<title>"+htmltemplate.HTMLEscapeString(msg.Subject)+"</title>") {
+					t.Error("html title does not repeat the subject")
+				}
+
+				if link, ok := data["Link"].(string); ok {
+					if !strings.Contains(msg.Text, link) {
+						t.Error("text body does not include the link")
+					}
+					if !strings.Contains(msg.HTML, `href="`+htmltemplate.HTMLEscapeString(link)+`"`) {
+						t.Error("html body does not link to the link")
+					}
+				}
+				if code, ok := data["Code"].(string); ok {
+					if !strings.Contains(msg.Text, code) || !strings.Contains(msg.HTML, code) {
+						t.Error("bodies do not include the code")
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRenderDirection(t *testing.T) {
+	templates := loadTestTemplates(t)
+
+	tests := []struct {
+		locale string
+		dir    string
+		align  string
+	}{
+		{locale: "en", dir: "ltr", align: "left"},
+		{locale: "de", dir: "ltr", align: "left"},
+		{locale: "fa", dir: "rtl", align: "right"},
+	}
+
+	for _, tt := range tests {
+		msg, err := templates.Render(TemplateVerifyEmail, tt.locale, DefaultBranding, SampleData(TemplateVerifyEmail))
+		if err != nil {
+			t.Fatalf("Render %s: %v", tt.locale, err)
+		}
+		if !strings.Contains(msg.HTML, `dir="`+tt.dir+`"`) || !strings.Contains(msg.HTML, "text-align:"+tt.align) {
+			t.Errorf("%s html is not laid out %s", tt.locale, tt.dir)
+		}
+	}
+}
+
+func TestRenderWithoutLogoShowsBrandName(t *testing.T) {
+	templates := loadTestTemplates(t)
+
+	msg, err := templates.Render(TemplatePasswordReset, "en", Branding{}, SampleData(TemplatePasswordReset))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if strings.Contains(msg.HTML, "<img") {
+		t.Error("html body shows a logo without a logo url")
+	}
+	if !strings.Contains(msg.Subject, DefaultBranding.Name) || !strings.Contains(msg.HTML, DefaultBranding.Name) {
+		t.Error("empty branding does not fall back to the default brand")
+	}
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	templates := loadTestTemplates(t)
+
+	for _, locale := range []string{"de-AT", "fa_IR", "fr", ""} {
+		msg, err := templates.Render(TemplateLoginCode, locale, DefaultBranding, SampleData(TemplateLoginCode))
+		if err != nil {
+			t.Fatalf("Render %q: %v", locale, err)
+		}
+		want := `lang="` + ResolveLocale(locale) + `"`
+		if !strings.Contains(msg.HTML, want) {
+			t.Errorf("Render %q did not use %s", locale, want)
+		}
+	}
+}
+
+func TestRenderRejectsMissingData(t *testing.T) {
+	templates := loadTestTemplates(t)
+
+	if _, err := templates.Render(TemplatePasswordReset, "en", DefaultBranding, map[string]any{"Name": "Jane"}); err == nil {
+		t.Fatal("Render succeeded without a link")
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEventType string
+
+const (
+	AuditEventRefreshTokenReuse AuditEventType = "refresh_token.reuse_detected"
+	AuditEventTokenRevoked      AuditEventType = "token.revoked"
+	AuditEventPasswordReset     AuditEventType = "user.password_reset"
+	AuditEventMFAEnrolled       AuditEventType = "user.mfa_enrolled"
+	AuditEventMFARemoved        AuditEventType = "user.mfa_removed"
+	AuditEventMFAReset          AuditEventType = "user.mfa_reset"
+	AuditEventAccountLocked     AuditEventType = "user.account_locked"
+	AuditEventAccountUnlocked   AuditEventType = "user.account_unlocked"
+
+	AuditEventWebAuthnSignCountRegression AuditEventType = "webauthn.sign_count_regression"
+)
+
+type AuditEvent struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey"`
+	Type      AuditEventType `gorm:"type:varchar(64);index;not null"`
+	UserID    *uuid.UUID     `gorm:"type:uuid;index"`
+	ClientID  string         `gorm:"type:varchar(128)"`
+	Metadata  map[string]any `gorm:"type:jsonb;not null;default:'{}';serializer:json"`
+	CreatedAt time.Time      `gorm:"index"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BackchannelLogoutStatus string
+
+const (
+	BackchannelLogoutStatusPending   BackchannelLogoutStatus = "pending"
+	BackchannelLogoutStatusDelivered BackchannelLogoutStatus = "delivered"
+	BackchannelLogoutStatusFailed    BackchannelLogoutStatus = "failed"
+)
+
+// BackchannelLogoutDelivery is a logout notification owed to a client. The
+// logout token is signed when the delivery is attempted, so none is stored.
+type BackchannelLogoutDelivery struct {
+	ID            uuid.UUID               `gorm:"type:uuid;primaryKey"`
+	ClientID      string                  `gorm:"type:varchar(128);index;not null"`
+	URI           string                  `gorm:"type:text;not null"`
+	Subject       string                  `gorm:"type:varchar(255);not null;default:''"`
+	SessionID     string                  `gorm:"type:varchar(64);not null"`
+	Status        BackchannelLogoutStatus `gorm:"type:varchar(16);not null;default:'pending';index:idx_backchannel_logout_due,priority:1"`
+	Attempts      int                     `gorm:"not null;default:0"`
+	NextAttemptAt time.Time               `gorm:"not null;index:idx_backchannel_logout_due,priority:2"`
+	LastError     string                  `gorm:"type:text;not null;default:''"`
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -13,15 +13,49 @@ const (
 	ClientTypeConfidential ClientType = "confidential"
 )
 
+type ClientAuthMethod string
+
+const (
+	ClientAuthMethodSecretBasic ClientAuthMethod = "client_secret_basic"
+	ClientAuthMethodSecretPost  ClientAuthMethod = "client_secret_post"
+	ClientAuthMethodNone        ClientAuthMethod = "none"
+)
+
+// LoginMethod is a primary sign-in method a client may offer its users.
+type LoginMethod string
+
+const (
+	LoginMethodPassword  LoginMethod = "password"
+	LoginMethodPasskey   LoginMethod = "passkey"
+	LoginMethodEmailLink LoginMethod = "email_link"
+	LoginMethodEmailCode LoginMethod = "email_code"
+)
+
+// DefaultLoginMethods applies to clients that do not configure any.
+var DefaultLoginMethods = []LoginMethod{LoginMethodPassword, LoginMethodPasskey}
+
 type Client struct {
-	ID                     uuid.UUID  `gorm:"type:uuid;primaryKey"`
-	ClientID               string     `gorm:"type:varchar(128);uniqueIndex;not null"`
-	Name                   string     `gorm:"type:varchar(255);not null"`
-	Type                   ClientType `gorm:"type:varchar(32);not null"`
-	SecretHash             *string    `gorm:"type:text"`
-	RedirectURIs           []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
-	PostLogoutRedirectURIs []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
-	Scopes                 []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
-	CreatedAt              time.Time
-	UpdatedAt              time.Time
+	ID                      uuid.UUID        `gorm:"type:uuid;primaryKey"`
+	ClientID                string           `gorm:"type:varchar(128);uniqueIndex;not null"`
+	Name                    string           `gorm:"type:varchar(255);not null"`
+	LogoURI                 string           `gorm:"type:text;not null;default:''"`
+	Type                    ClientType       `gorm:"type:varchar(32);not null"`
+	SecretHash              *string          `gorm:"type:text"`
+	TokenEndpointAuthMethod ClientAuthMethod `gorm:"type:varchar(32);not null;default:'client_secret_basic'"`
+	RedirectURIs            []string         `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	PostLogoutRedirectURIs  []string         `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	BackchannelLogoutURI    string           `gorm:"type:text;not null;default:''"`
+	FrontchannelLogoutURI   string           `gorm:"type:text;not null;default:''"`
+	// FrontchannelLogoutSessionRequired asks for iss and sid on the
+	// front-channel logout URI.
+	FrontchannelLogoutSessionRequired bool     `gorm:"not null;default:false"`
+	Scopes                            []string `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	// LoginMethods lists the primary sign-in methods offered for this
+	// client; empty means DefaultLoginMethods.
+	LoginMethods []LoginMethod `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	// AllowedAudiences lists the audience and resource values the client may
+	// request for client credentials tokens besides its own client ID.
+	AllowedAudiences []string `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailOutboxStatus string
+
+const (
+	EmailOutboxStatusPending EmailOutboxStatus = "pending"
+	EmailOutboxStatusSent    EmailOutboxStatus = "sent"
+	EmailOutboxStatusFailed  EmailOutboxStatus = "failed"
+)
+
+// EmailOutboxMessage is a rendered email waiting for delivery. Rows are
+// written in the same transaction as the change that triggered them. Bodies
+// are blanked once sent and rows are purged after a retention window.
+type EmailOutboxMessage struct {
+	ID            uuid.UUID         `gorm:"type:uuid;primaryKey"`
+	Template      string            `gorm:"type:varchar(64);not null"`
+	Recipients    []string          `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	Subject       string            `gorm:"type:text;not null"`
+	TextBody      string            `gorm:"type:text;not null"`
+	HTMLBody      string            `gorm:"type:text;not null;default:''"`
+	Status        EmailOutboxStatus `gorm:"type:varchar(16);not null;default:'pending';index:idx_email_outbox_due,priority:1"`
+	Attempts      int               `gorm:"not null;default:0"`
+	NextAttemptAt time.Time         `gorm:"not null;index:idx_email_outbox_due,priority:2"`
+	LastError     string            `gorm:"type:text;not null;default:''"`
+	SentAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MFAFactorType string
+
+const (
+	MFAFactorTOTP MFAFactorType = "totp"
+)
+
+// MFAFactor is a second factor enrolled by a user. A factor only counts once
+// ConfirmedAt is set, i.e. the user proved they can produce a code.
+type MFAFactor struct {
+	ID              uuid.UUID     `gorm:"type:uuid;primaryKey"`
+	UserID          uuid.UUID     `gorm:"type:uuid;index;not null"`
+	Type            MFAFactorType `gorm:"type:varchar(32);not null"`
+	Label           string        `gorm:"type:varchar(255);not null;default:''"`
+	SecretEncrypted string        `gorm:"type:text;not null"`
+	// LastUsedStep is the TOTP time step of the last accepted code; codes for
+	// that step or earlier are replays.
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	ConfirmedAt  *time.Time
+	LastUsedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// MFABackupCode is one single-use recovery code. Only its hash is stored.
+type MFABackupCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null"`
+	CodeHash  string    `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
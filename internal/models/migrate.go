@@ -3,5 +3,21 @@ package models
 import "gorm.io/gorm"
 
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(&User{}, &Client{})
+	if err := db.AutoMigrate(&User{}, &Client{}, &SigningKey{}, &RefreshToken{}, &AuditEvent{}, &EmailOutboxMessage{}, &MFAFactor{}, &MFABackupCode{}, &WebAuthnCredential{}, &BackchannelLogoutDelivery{}); err != nil {
+		return err
+	}
+
+	return backfillClientAuthMethods(db)
+}
+
+// backfillClientAuthMethods fixes public clients created before
+// token_endpoint_auth_method existed. Adding the column gave every row the
+// client_secret_basic default, which public clients cannot satisfy.
+// Confidential clients keep a secret method even without a secret, so they
+// fail closed instead of authenticating with their client_id alone.
+func backfillClientAuthMethods(db *gorm.DB) error {
+	return db.Model(&Client{}).
+		Where("type = ?", ClientTypePublic).
+		Where("token_endpoint_auth_method <> ?", ClientAuthMethodNone).
+		Update("token_endpoint_auth_method", ClientAuthMethodNone).Error
 }
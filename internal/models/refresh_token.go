@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	FamilyID   uuid.UUID  `gorm:"type:uuid;index;not null"`
+	ParentID   *uuid.UUID `gorm:"type:uuid;index"`
+	ClientID   string     `gorm:"type:varchar(128);index;not null"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index;not null"`
+	SessionID  string     `gorm:"type:varchar(64);index;not null;default:''"`
+	Scopes     []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	AuthTime   time.Time  `gorm:"not null"`
+	AMR        []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	ACR        string     `gorm:"type:varchar(64);not null;default:''"`
+	ExpiresAt  time.Time  `gorm:"not null;index"`
+	ConsumedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SigningAlgorithm string
+
+const (
+	SigningAlgorithmRS256 SigningAlgorithm = "RS256"
+	SigningAlgorithmES256 SigningAlgorithm = "ES256"
+)
+
+type SigningKeyState string
+
+const (
+	SigningKeyStateActive  SigningKeyState = "active"
+	SigningKeyStateRetired SigningKeyState = "retired"
+)
+
+type SigningKey struct {
+	ID          uuid.UUID        `gorm:"type:uuid;primaryKey"`
+	KID         string           `gorm:"column:kid;type:varchar(128);uniqueIndex;not null"`
+	Algorithm   SigningAlgorithm `gorm:"type:varchar(16);not null;index"`
+	State       SigningKeyState  `gorm:"type:varchar(32);not null;default:'active';index"`
+	PrivateKey  string           `gorm:"type:text;not null"`
+	PublicKey   string           `gorm:"type:text;not null"`
+	ActivatedAt time.Time        `gorm:"not null"`
+	RetiredAt   *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -19,7 +19,10 @@ type User struct {
 	Email         string     `gorm:"type:varchar(320);uniqueIndex;not null"`
 	EmailVerified bool       `gorm:"not null;default:false"`
 	PasswordHash  string     `gorm:"type:text;not null"`
-	MFAFactors    []string   `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	Name          string     `gorm:"type:varchar(255);not null;default:''"`
+	GivenName     string     `gorm:"type:varchar(255);not null;default:''"`
+	FamilyName    string     `gorm:"type:varchar(255);not null;default:''"`
+	Locale        string     `gorm:"type:varchar(35);not null;default:''"`
 	Status        UserStatus `gorm:"type:varchar(32);not null;default:'pending'"`
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
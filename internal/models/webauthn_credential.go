@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a registered security key or passkey. It serves as a
+// second factor after a password and, when discoverable, as a passwordless
+// sign-in method.
+type WebAuthnCredential struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID       uuid.UUID `gorm:"type:uuid;index;not null"`
+	CredentialID []byte    `gorm:"type:bytea;uniqueIndex;not null"`
+	// PublicKey is the credential public key in COSE_Key form.
+	PublicKey  []byte    `gorm:"type:bytea;not null"`
+	Algorithm  int64     `gorm:"not null"`
+	SignCount  int64     `gorm:"not null;default:0"`
+	Transports []string  `gorm:"type:jsonb;not null;default:'[]';serializer:json"`
+	AAGUID     uuid.UUID `gorm:"type:uuid;not null"`
+	Label      string    `gorm:"type:varchar(255);not null;default:''"`
+	// BackupEligible marks credentials that may be synced between devices
+	// (passkeys); BackupState whether they currently are.
+	BackupEligible bool `gorm:"not null;default:false"`
+	BackupState    bool `gorm:"not null;default:false"`
+	LastUsedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
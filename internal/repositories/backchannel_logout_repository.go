@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+var ErrBackchannelDeliveryNotFound = errors.New("back-channel logout delivery not found")
+
+type BackchannelLogoutRepository interface {
+	Create(ctx context.Context, delivery *models.BackchannelLogoutDelivery) error
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.BackchannelLogoutDelivery, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID, deliveredAt time.Time) error
+	MarkRetry(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type backchannelLogoutRepository struct {
+	db *gorm.DB
+}
+
+func NewBackchannelLogoutRepository(db *gorm.DB) BackchannelLogoutRepository {
+	return &backchannelLogoutRepository{db: db}
+}
+
+// Create joins the transaction bound to ctx, if any.
+func (r *backchannelLogoutRepository) Create(ctx context.Context, delivery *models.BackchannelLogoutDelivery) error {
+	return conn(ctx, r.db).Create(delivery).Error
+}
+
+// ClaimDue leases up to limit due deliveries the same way the email outbox
+// does: concurrent workers skip locked rows and an expired lease makes a
+// delivery due again.
+func (r *backchannelLogoutRepository) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.BackchannelLogoutDelivery, error) {
+	var deliveries []models.BackchannelLogoutDelivery
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.BackchannelLogoutStatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+
+		return tx.Model(&models.BackchannelLogoutDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *backchannelLogoutRepository) MarkDelivered(ctx context.Context, id uuid.UUID, deliveredAt time.Time) error {
+	return r.update(ctx, id, map[string]any{
+		"status":       models.BackchannelLogoutStatusDelivered,
+		"delivered_at": deliveredAt,
+		"last_error":   "",
+	})
+}
+
+func (r *backchannelLogoutRepository) MarkRetry(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return r.update(ctx, id, map[string]any{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	})
+}
+
+func (r *backchannelLogoutRepository) MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string) error {
+	return r.update(ctx, id, map[string]any{
+		"status":     models.BackchannelLogoutStatusFailed,
+		"attempts":   attempts,
+		"last_error": lastError,
+	})
+}
+
+// Purge deletes finished deliveries last touched before before.
+func (r *backchannelLogoutRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("status IN ? AND updated_at < ?",
+			[]models.BackchannelLogoutStatus{models.BackchannelLogoutStatusDelivered, models.BackchannelLogoutStatusFailed},
+			before).
+		Delete(&models.BackchannelLogoutDelivery{})
+
+	return result.RowsAffected, result.Error
+}
+
+func (r *backchannelLogoutRepository) update(ctx context.Context, id uuid.UUID, values map[string]any) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.BackchannelLogoutDelivery{}).
+		Where("id = ?", id).
+		Updates(values)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBackchannelDeliveryNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+var ErrOutboxMessageNotFound = errors.New("outbox message not found")
+
+// EmailOutboxStats describes the delivery queue for monitoring.
+type EmailOutboxStats struct {
+	Pending       int64
+	Failed        int64
+	OldestPending *time.Time
+}
+
+type EmailOutboxRepository interface {
+	Create(ctx context.Context, message *models.EmailOutboxMessage) error
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.EmailOutboxMessage, error)
+	MarkSent(ctx context.Context, id uuid.UUID, sentAt time.Time) error
+	MarkRetry(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string) error
+	ListFailed(ctx context.Context, offset, limit int) ([]models.EmailOutboxMessage, error)
+	Requeue(ctx context.Context, id uuid.UUID, now time.Time) error
+	Stats(ctx context.Context) (*EmailOutboxStats, error)
+	Purge(ctx context.Context, sentBefore, failedBefore time.Time) (int64, error)
+}
+
+type emailOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailOutboxRepository(db *gorm.DB) EmailOutboxRepository {
+	return &emailOutboxRepository{db: db}
+}
+
+// Create joins the transaction bound to ctx, if any.
+func (r *emailOutboxRepository) Create(ctx context.Context, message *models.EmailOutboxMessage) error {
+	return conn(ctx, r.db).Create(message).Error
+}
+
+// ClaimDue leases up to limit due messages by pushing their next attempt past
+// the lease, so concurrent workers skip them. A worker that dies mid-send
+// lets the lease expire and the message is picked up again.
+func (r *emailOutboxRepository) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]models.EmailOutboxMessage, error) {
+	var messages []models.EmailOutboxMessage
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.EmailOutboxStatusPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+		}
+
+		return tx.Model(&models.EmailOutboxMessage{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkSent also blanks the bodies: they hold single-use links and codes that
+// must not outlive delivery.
+func (r *emailOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID, sentAt time.Time) error {
+	return r.update(ctx, id, map[string]any{
+		"status":     models.EmailOutboxStatusSent,
+		"sent_at":    sentAt,
+		"last_error": "",
+		"text_body":  "",
+		"html_body":  "",
+	})
+}
+
+func (r *emailOutboxRepository) MarkRetry(ctx context.Context, id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return r.update(ctx, id, map[string]any{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	})
+}
+
+func (r *emailOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string) error {
+	return r.update(ctx, id, map[string]any{
+		"status":     models.EmailOutboxStatusFailed,
+		"attempts":   attempts,
+		"last_error": lastError,
+	})
+}
+
+func (r *emailOutboxRepository) ListFailed(ctx context.Context, offset, limit int) ([]models.EmailOutboxMessage, error) {
+	var messages []models.EmailOutboxMessage
+
+	query := r.db.WithContext(ctx).
+		Where("status = ?", models.EmailOutboxStatusFailed).
+		Order("updated_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Requeue moves a failed message back to pending with a fresh attempt budget.
+func (r *emailOutboxRepository) Requeue(ctx context.Context, id uuid.UUID, now time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.EmailOutboxMessage{}).
+		Where("id = ? AND status = ?", id, models.EmailOutboxStatusFailed).
+		Updates(map[string]any{
+			"status":          models.EmailOutboxStatusPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOutboxMessageNotFound
+	}
+
+	return nil
+}
+
+func (r *emailOutboxRepository) Stats(ctx context.Context) (*EmailOutboxStats, error) {
+	var rows []struct {
+		Status models.EmailOutboxStatus
+		Count  int64
+		Oldest *time.Time
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.EmailOutboxMessage{}).
+		Select("status, COUNT(*) AS count, MIN(created_at) AS oldest").
+		Where("status IN ?", []models.EmailOutboxStatus{models.EmailOutboxStatusPending, models.EmailOutboxStatusFailed}).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &EmailOutboxStats{}
+	for _, row := range rows {
+		switch row.Status {
+		case models.EmailOutboxStatusPending:
+			stats.Pending = row.Count
+			stats.OldestPending = row.Oldest
+		case models.EmailOutboxStatusFailed:
+			stats.Failed = row.Count
+		}
+	}
+
+	return stats, nil
+}
+
+// Purge deletes sent messages older than sentBefore and failed ones last
+// touched before failedBefore, returning how many rows went.
+func (r *emailOutboxRepository) Purge(ctx context.Context, sentBefore, failedBefore time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("(status = ? AND sent_at < ?) OR (status = ? AND updated_at < ?)",
+			models.EmailOutboxStatusSent, sentBefore,
+			models.EmailOutboxStatusFailed, failedBefore).
+		Delete(&models.EmailOutboxMessage{})
+
+	return result.RowsAffected, result.Error
+}
+
+func (r *emailOutboxRepository) update(ctx context.Context, id uuid.UUID, values map[string]any) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.EmailOutboxMessage{}).
+		Where("id = ?", id).
+		Updates(values)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOutboxMessageNotFound
+	}
+
+	return nil
+}
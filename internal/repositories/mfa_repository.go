@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+var (
+	ErrMFAFactorNotFound  = errors.New("mfa factor not found")
+	ErrMFACodeAlreadyUsed = errors.New("mfa code already used")
+	ErrBackupCodeNotFound = errors.New("backup code not found")
+)
+
+type MFARepository interface {
+	CreateFactor(ctx context.Context, factor *models.MFAFactor) error
+	GetFactor(ctx context.Context, userID, id uuid.UUID) (*models.MFAFactor, error)
+	ListFactors(ctx context.Context, userID uuid.UUID) ([]models.MFAFactor, error)
+	ConfirmFactor(ctx context.Context, id uuid.UUID, step int64, confirmedAt time.Time) error
+	MarkFactorUsed(ctx context.Context, id uuid.UUID, step int64, usedAt time.Time) error
+	DeleteFactor(ctx context.Context, userID, id uuid.UUID) error
+	DeleteFactorsByUser(ctx context.Context, userID uuid.UUID) error
+	ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	UseBackupCode(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time) error
+	CountUnusedBackupCodes(ctx context.Context, userID uuid.UUID) (int64, error)
+	DeleteBackupCodes(ctx context.Context, userID uuid.UUID) error
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) CreateFactor(ctx context.Context, factor *models.MFAFactor) error {
+	return conn(ctx, r.db).Create(factor).Error
+}
+
+func (r *mfaRepository) GetFactor(ctx context.Context, userID, id uuid.UUID) (*models.MFAFactor, error) {
+	var factor models.MFAFactor
+	err := conn(ctx, r.db).First(&factor, "id = ? AND user_id = ?", id, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFAFactorNotFound
+		}
+		return nil, err
+	}
+	return &factor, nil
+}
+
+func (r *mfaRepository) ListFactors(ctx context.Context, userID uuid.UUID) ([]models.MFAFactor, error) {
+	var factors []models.MFAFactor
+	err := conn(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&factors).Error
+	if err != nil {
+		return nil, err
+	}
+	return factors, nil
+}
+
+func (r *mfaRepository) ConfirmFactor(ctx context.Context, id uuid.UUID, step int64, confirmedAt time.Time) error {
+	result := conn(ctx, r.db).
+		Model(&models.MFAFactor{}).
+		Where("id = ? AND confirmed_at IS NULL", id).
+		Updates(map[string]any{
+			"confirmed_at":   confirmedAt,
+			"last_used_at":   confirmedAt,
+			"last_used_step": step,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAFactorNotFound
+	}
+
+	return nil
+}
+
+// MarkFactorUsed records the accepted step. The update only applies when the
+// step moves forward, so two concurrent logins with the same code cannot
+// both succeed.
+func (r *mfaRepository) MarkFactorUsed(ctx context.Context, id uuid.UUID, step int64, usedAt time.Time) error {
+	result := conn(ctx, r.db).
+		Model(&models.MFAFactor{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Updates(map[string]any{
+			"last_used_at":   usedAt,
+			"last_used_step": step,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFACodeAlreadyUsed
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) DeleteFactor(ctx context.Context, userID, id uuid.UUID) error {
+	result := conn(ctx, r.db).Delete(&models.MFAFactor{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAFactorNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteFactorsByUser(ctx context.Context, userID uuid.UUID) error {
+	return conn(ctx, r.db).Delete(&models.MFAFactor{}, "user_id = ?", userID).Error
+}
+
+// ReplaceBackupCodes invalidates every existing code of the user and stores
+// the new set.
+func (r *mfaRepository) ReplaceBackupCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	codes := make([]models.MFABackupCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.MFABackupCode{ID: uuid.New(), UserID: userID, CodeHash: hash})
+	}
+
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.MFABackupCode{}, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mfaRepository) UseBackupCode(ctx context.Context, userID uuid.UUID, codeHash string, usedAt time.Time) error {
+	result := conn(ctx, r.db).
+		Model(&models.MFABackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", usedAt)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrBackupCodeNotFound
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) CountUnusedBackupCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).
+		Model(&models.MFABackupCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *mfaRepository) DeleteBackupCodes(ctx context.Context, userID uuid.UUID) error {
+	return conn(ctx, r.db).Delete(&models.MFABackupCode{}, "user_id = ?", userID).Error
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+var (
+	ErrRefreshTokenNotFound        = errors.New("refresh token not found")
+	ErrRefreshTokenAlreadyConsumed = errors.New("refresh token already consumed")
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	MarkConsumed(ctx context.Context, id uuid.UUID, consumedAt time.Time) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, revokedAt time.Time) error
+	RevokeBySession(ctx context.Context, sessionID string, revokedAt time.Time) error
+	RevokeByUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.WithContext(ctx).First(&token, "token_hash = ?", tokenHash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkConsumed flags the token as used. Only one caller can win: any other
+// concurrent attempt receives ErrRefreshTokenAlreadyConsumed.
+func (r *refreshTokenRepository) MarkConsumed(ctx context.Context, id uuid.UUID, consumedAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", consumedAt)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefreshTokenAlreadyConsumed
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, revokedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", revokedAt).Error
+}
+
+func (r *refreshTokenRepository) RevokeBySession(ctx context.Context, sessionID string, revokedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", revokedAt).Error
+}
+
+func (r *refreshTokenRepository) RevokeByUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+var ErrSigningKeyNotFound = errors.New("signing key not found")
+
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *models.SigningKey) error
+	GetByKID(ctx context.Context, kid string) (*models.SigningKey, error)
+	GetActive(ctx context.Context, algorithm models.SigningAlgorithm) (*models.SigningKey, error)
+	ListPublishable(ctx context.Context, retiredAfter time.Time) ([]models.SigningKey, error)
+	Retire(ctx context.Context, id uuid.UUID, retiredAt time.Time) error
+}
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *signingKeyRepository) GetByKID(ctx context.Context, kid string) (*models.SigningKey, error) {
+	var key models.SigningKey
+	err := r.db.WithContext(ctx).First(&key, "kid = ?", kid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSigningKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *signingKeyRepository) GetActive(ctx context.Context, algorithm models.SigningAlgorithm) (*models.SigningKey, error) {
+	var key models.SigningKey
+	err := r.db.WithContext(ctx).
+		Where("algorithm = ? AND state = ?", algorithm, models.SigningKeyStateActive).
+		Order("activated_at DESC").
+		First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSigningKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListPublishable returns every active key plus the keys retired after
+// retiredAfter, so tokens they signed can still be verified.
+func (r *signingKeyRepository) ListPublishable(ctx context.Context, retiredAfter time.Time) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+
+	err := r.db.WithContext(ctx).
+		Where("state = ? OR (state = ? AND retired_at > ?)", models.SigningKeyStateActive, models.SigningKeyStateRetired, retiredAfter).
+		Order("activated_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *signingKeyRepository) Retire(ctx context.Context, id uuid.UUID, retiredAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.SigningKey{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"state": models.SigningKeyStateRetired, "retired_at": retiredAt})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSigningKeyNotFound
+	}
+
+	return nil
+}
@@ -18,7 +18,10 @@ var (
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	List(ctx context.Context, offset, limit int) ([]models.User, error)
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
 }
 
 type userRepository struct {
@@ -30,7 +33,7 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 }
 
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
-	err := r.db.WithContext(ctx).Create(user).Error
+	err := conn(ctx, r.db).Create(user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return ErrUserAlreadyExists
@@ -42,7 +45,20 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) error {
 
 func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error
+	err := conn(ctx, r.db).First(&user, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByEmail matches the address case-insensitively.
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := conn(ctx, r.db).First(&user, "LOWER(email) = LOWER(?)", email).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
@@ -55,7 +71,7 @@ func (r *userRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Use
 func (r *userRepository) List(ctx context.Context, offset, limit int) ([]models.User, error) {
 	var users []models.User
 
-	query := r.db.WithContext(ctx).Order("created_at DESC")
+	query := conn(ctx, r.db).Order("created_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -69,3 +85,43 @@ func (r *userRepository) List(ctx context.Context, offset, limit int) ([]models.
 
 	return users, nil
 }
+
+func (r *userRepository) UpdatePasswordHash(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	result := conn(ctx, r.db).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Update("password_hash", passwordHash)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// MarkEmailVerified sets email_verified and promotes a pending account to
+// active. Disabled accounts stay disabled.
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	result := conn(ctx, r.db).
+		Model(&models.User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"email_verified": true,
+			"status": gorm.Expr(
+				"CASE WHEN status = ? THEN ? ELSE status END",
+				models.UserStatusPending, models.UserStatusActive,
+			),
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
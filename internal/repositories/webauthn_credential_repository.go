@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+var ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+
+type WebAuthnCredentialRepository interface {
+	Create(ctx context.Context, credential *models.WebAuthnCredential) error
+	GetByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	UpdateUsage(ctx context.Context, id uuid.UUID, signCount int64, backupState bool, usedAt time.Time) error
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type webAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnCredentialRepository(db *gorm.DB) WebAuthnCredentialRepository {
+	return &webAuthnCredentialRepository{db: db}
+}
+
+func (r *webAuthnCredentialRepository) Create(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return conn(ctx, r.db).Create(credential).Error
+}
+
+func (r *webAuthnCredentialRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := conn(ctx, r.db).First(&credential, "credential_id = ?", credentialID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnCredentialRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := conn(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&credentials).Error
+	if err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (r *webAuthnCredentialRepository) UpdateUsage(ctx context.Context, id uuid.UUID, signCount int64, backupState bool, usedAt time.Time) error {
+	result := conn(ctx, r.db).
+		Model(&models.WebAuthnCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"sign_count":   signCount,
+			"backup_state": backupState,
+			"last_used_at": usedAt,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
+func (r *webAuthnCredentialRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result := conn(ctx, r.db).Delete(&models.WebAuthnCredential{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return nil
+}
+
+func (r *webAuthnCredentialRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	return conn(ctx, r.db).Delete(&models.WebAuthnCredential{}, "user_id = ?", userID).Error
+}
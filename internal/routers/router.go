@@ -1,27 +1,177 @@
 package routers
 
 import (
+	"context"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
+	"github.com/mohammadhprp/passport/internal/config"
 	"github.com/mohammadhprp/passport/internal/handlers"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
 	"github.com/mohammadhprp/passport/internal/repositories"
 	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/sessions"
 )
 
-func NewRouter(db *gorm.DB) *gin.Engine {
+// Worker is a background loop behind one of the router's services. It runs
+// until ctx is cancelled.
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// NewRouter wires the HTTP API. The returned workers must be run by the
+// caller for as long as the server is up.
+func NewRouter(cfg config.Config, db *gorm.DB, redisClient *redis.Client, mailTemplates *mailer.Templates) (*gin.Engine, []Worker) {
 	router := gin.Default()
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "up"})
 	})
 
+	signingKeyRepo := repositories.NewSigningKeyRepository(db)
+	keyManager := keys.NewManager(signingKeyRepo, models.SigningAlgorithm(cfg.SSO.SigningAlgorithm), keyRetention(cfg.SSO.Tokens))
+	discoveryHandler := handlers.NewDiscoveryHandler(cfg.SSO, keyManager)
+
+	wellKnownRoutes := router.Group("/.well-known")
+	discoveryHandler.RegisterRoutes(wellKnownRoutes)
+
+	cacheService := services.NewRedisCacheService(redisClient)
+	sessionStore := sessions.NewStore(cacheService, cfg.SSO)
+	router.Use(handlers.LoadSession(sessionStore, cfg.SSO.Cookie))
+
+	clientRepo := repositories.NewClientRepository(db)
+	authorizationService := services.NewAuthorizationService(clientRepo, cacheService, cfg.SSO)
+
+	auditRepo := repositories.NewAuditRepository(db)
+	auditService := services.NewAuditService(auditRepo)
+
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	refreshTokenService := services.NewRefreshTokenService(refreshTokenRepo, auditService, cfg.SSO.Tokens)
+
+	clientService := services.NewClientService(clientRepo)
+	deviceService := services.NewDeviceAuthorizationService(cacheService, cfg.SSO)
+	deviceHandler := handlers.NewDeviceHandler(clientService, deviceService)
+
+	transactor := repositories.NewTransactor(db)
+
+	emailOutboxRepo := repositories.NewEmailOutboxRepository(db)
+	emailOutboxService := services.NewEmailOutboxService(emailOutboxRepo, mailer.NewSMTPMailer(cfg))
+	emailOutboxHandler := handlers.NewEmailOutboxHandler(emailOutboxService)
+
+	emailService := services.NewEmailService(mailTemplates, emailOutboxRepo, clientRepo)
+	emailTemplateHandler := handlers.NewEmailTemplateHandler(mailTemplates, clientService)
+
 	userRepo := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepo)
+	emailVerificationService := services.NewEmailVerificationService(userRepo, keyManager, cacheService, emailService, cfg.SSO)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(emailVerificationService)
+	userService := services.NewUserService(userRepo, transactor, emailVerificationService)
 	userHandler := handlers.NewUserHandler(userService)
 
+	backchannelLogoutRepo := repositories.NewBackchannelLogoutRepository(db)
+	backchannelDispatcher := services.NewBackchannelLogoutDispatcher(backchannelLogoutRepo, keyManager, cfg.SSO)
+
+	logoutService := services.NewLogoutService(
+		clientRepo,
+		keyManager,
+		sessionStore,
+		refreshTokenService,
+		backchannelDispatcher,
+		cacheService,
+		cfg.SSO,
+	)
+
+	tokenService := services.NewTokenService(
+		authorizationService,
+		deviceService,
+		refreshTokenService,
+		logoutService,
+		userRepo,
+		keyManager,
+		cacheService,
+		cfg.SSO,
+	)
+	tokenHandler := handlers.NewTokenHandler(clientService, tokenService)
+	userInfoHandler := handlers.NewUserInfoHandler(userService, tokenService)
+
+	introspectionService := services.NewIntrospectionService(tokenService, refreshTokenService, cacheService)
+	introspectionHandler := handlers.NewIntrospectionHandler(clientService, introspectionService)
+
+	revocationService := services.NewRevocationService(tokenService, refreshTokenService, cacheService, auditService)
+	revocationHandler := handlers.NewRevocationHandler(clientService, revocationService)
+
+	webAuthnCredentialRepo := repositories.NewWebAuthnCredentialRepository(db)
+	webAuthnService := services.NewWebAuthnService(webAuthnCredentialRepo, userRepo, cacheService, auditService, cfg.SSO)
+
+	mfaRepo := repositories.NewMFARepository(db)
+	mfaService := services.NewMFAService(mfaRepo, userRepo, webAuthnService, cacheService, emailService, auditService, cfg.SSO)
+	mfaHandler := handlers.NewMFAHandler(mfaService, webAuthnService)
+	authorizeHandler := handlers.NewAuthorizeHandler(authorizationService, mfaService)
+
+	emailLoginService := services.NewEmailLoginService(userRepo, keyManager, cacheService, emailService, cfg.SSO)
+
+	loginThrottleService := services.NewLoginThrottleService(userRepo, cacheService, emailService, auditService, cfg.SSO)
+	lockoutHandler := handlers.NewLockoutHandler(loginThrottleService)
+
+	endSessionHandler := handlers.NewEndSessionHandler(logoutService, cfg.SSO)
+	loginHandler := handlers.NewLoginHandler(
+		userService,
+		authorizationService,
+		sessionStore,
+		logoutService,
+		mfaService,
+		webAuthnService,
+		emailLoginService,
+		loginThrottleService,
+		cfg.SSO,
+	)
+	sessionHandler := handlers.NewSessionHandler(sessionStore, logoutService, cfg.SSO)
+
+	passwordResetService := services.NewPasswordResetService(userRepo, cacheService, emailService, logoutService, auditService, cfg.SSO)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService, cfg.SSO)
+
+	requireAdmin := handlers.RequireAdmin(tokenService)
+
+	authorizeHandler.RegisterRoutes(&router.RouterGroup)
+	tokenHandler.RegisterRoutes(&router.RouterGroup)
+	deviceHandler.RegisterRoutes(&router.RouterGroup)
+	userInfoHandler.RegisterRoutes(&router.RouterGroup)
+	introspectionHandler.RegisterRoutes(&router.RouterGroup)
+	revocationHandler.RegisterRoutes(&router.RouterGroup)
+	endSessionHandler.RegisterRoutes(&router.RouterGroup)
+	loginHandler.RegisterRoutes(&router.RouterGroup)
+	emailVerificationHandler.RegisterRoutes(&router.RouterGroup)
+	passwordResetHandler.RegisterRoutes(&router.RouterGroup)
+
+	emailOutboxRoutes := router.Group("/email-outbox", requireAdmin...)
+	emailOutboxHandler.RegisterRoutes(emailOutboxRoutes)
+
+	emailTemplateRoutes := router.Group("/email-templates", requireAdmin...)
+	emailTemplateHandler.RegisterRoutes(emailTemplateRoutes)
+
+	sessionRoutes := router.Group("/sessions")
+	sessionHandler.RegisterRoutes(sessionRoutes)
+
+	mfaRoutes := router.Group("/mfa")
+	mfaHandler.RegisterRoutes(mfaRoutes)
+
 	userRoutes := router.Group("/users")
 	userHandler.RegisterRoutes(userRoutes)
 
-	return router
+	adminUserRoutes := router.Group("/users", requireAdmin...)
+	sessionHandler.RegisterUserRoutes(adminUserRoutes)
+	mfaHandler.RegisterUserRoutes(adminUserRoutes)
+	lockoutHandler.RegisterUserRoutes(adminUserRoutes)
+
+	return router, []Worker{emailOutboxService, backchannelDispatcher}
+}
+
+// keyRetention is how long a retired signing key stays in the JWKS: long
+// enough for every JWT it signed to expire.
+func keyRetention(tokens config.TokenTTLConfig) time.Duration {
+	return max(tokens.AccessToken, tokens.IDToken)
 }
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Authentication context class references, weakest first. They are
+// advertised in discovery and requested by clients through acr_values.
+const (
+	ACRSingleFactor = "urn:passport:acr:1fa"
+	ACRMultiFactor  = "urn:passport:acr:mfa"
+)
+
+// SupportedACRValues lists the ACRs this server can satisfy, weakest first.
+var SupportedACRValues = []string{ACRSingleFactor, ACRMultiFactor}
+
+// SessionDecision is what /authorize must do with an existing session.
+type SessionDecision int
+
+const (
+	// SessionSatisfies means a code can be issued from the session as is.
+	SessionSatisfies SessionDecision = iota
+	// SessionReauthenticate means the user must sign in again, because of
+	// prompt=login or an auth_time older than max_age.
+	SessionReauthenticate
+	// SessionStepUp means the session is recent enough but its ACR is below
+	// the one required; a second factor raises it.
+	SessionStepUp
+)
+
+// ACRForAMR derives the ACR a login achieved from its method references.
+func ACRForAMR(amr []string) string {
+	if slices.Contains(amr, AMRMFA) {
+		return ACRMultiFactor
+	}
+	return ACRSingleFactor
+}
+
+// ACRSatisfies reports whether achieved is at least as strong as required.
+// An empty requirement is always met.
+func ACRSatisfies(achieved, required string) bool {
+	if required == "" {
+		return true
+	}
+	return acrLevel(achieved) >= acrLevel(required)
+}
+
+// EvaluateSession decides whether auth, the caller's current session, is
+// good enough for req at now.
+func EvaluateSession(req *AuthorizationRequest, auth Authentication, now time.Time) SessionDecision {
+	if req.Prompt == "login" {
+		return SessionReauthenticate
+	}
+	if req.MaxAge != nil && now.Sub(auth.AuthTime) > time.Duration(*req.MaxAge)*time.Second {
+		return SessionReauthenticate
+	}
+	if !ACRSatisfies(auth.ACR, req.RequiredACR) {
+		return SessionStepUp
+	}
+	return SessionSatisfies
+}
+
+// requiredACR combines the acr_values of a request with the minimum ACRs
+// of its scopes. acr_values lists acceptable values, so the weakest
+// recognised one applies; scope minimums cannot be lowered by it.
+func requiredACR(acrValues []string, scopes []string, scopeMinimums map[string]string) string {
+	required := ""
+	for _, value := range acrValues {
+		if acrLevel(value) == 0 {
+			continue
+		}
+		if required == "" || acrLevel(value) < acrLevel(required) {
+			required = value
+		}
+	}
+
+	for _, scope := range scopes {
+		if minimum, ok := scopeMinimums[scope]; ok && acrLevel(minimum) > acrLevel(required) {
+			required = minimum
+		}
+	}
+
+	return required
+}
+
+// acrLevel ranks acr; unknown values rank 0.
+func acrLevel(acr string) int {
+	return slices.Index(SupportedACRValues, strings.TrimSpace(acr)) + 1
+}
@@ -0,0 +1,13 @@
+package services
+
+// Authentication method references (RFC 8176) recorded on sessions and
+// reported to relying parties. AMREmail is not registered; it marks proof of
+// control over the account's email address.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRMFA         = "mfa"
+	AMRHardwareKey = "hwk"
+	AMRSoftwareKey = "swk"
+	AMREmail       = "email"
+)
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+type RecordAuditEventParams struct {
+	Type     models.AuditEventType
+	UserID   *uuid.UUID
+	ClientID string
+	Metadata map[string]any
+}
+
+type AuditService interface {
+	Record(ctx context.Context, params RecordAuditEventParams) error
+}
+
+type auditService struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditService(repo repositories.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) Record(ctx context.Context, params RecordAuditEventParams) error {
+	metadata := params.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	event := &models.AuditEvent{
+		ID:       uuid.New(),
+		Type:     params.Type,
+		UserID:   params.UserID,
+		ClientID: params.ClientID,
+		Metadata: metadata,
+	}
+
+	return s.repo.Create(ctx, event)
+}
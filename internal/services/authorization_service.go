@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	authorizationCodeEntropyBytes = 32
+	loginStateEntropyBytes        = 24
+
+	authorizationCodeKeyPrefix = "authorization_code:"
+	loginStateKeyPrefix        = "login_state:"
+
+	CodeChallengeMethodS256 = "S256"
+)
+
+var (
+	// ErrInvalidAuthorizeClient and ErrRedirectURIMismatch must never be sent to
+	// the redirect_uri: the request cannot be trusted enough to redirect.
+	ErrInvalidAuthorizeClient = errors.New("unknown client_id")
+	ErrRedirectURIMismatch    = errors.New("redirect_uri does not match a registered uri")
+
+	ErrAuthorizationCodeNotFound = errors.New("authorization code is invalid or expired")
+	ErrLoginStateNotFound        = errors.New("login state is invalid or expired")
+)
+
+type AuthorizeParams struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+	MaxAge              string
+	ACRValues           string
+}
+
+// AuthorizationRequest is a validated /authorize request. It is safe to
+// redirect errors to RedirectURI once one has been obtained.
+type AuthorizationRequest struct {
+	ClientID            string   `json:"client_id"`
+	RedirectURI         string   `json:"redirect_uri"`
+	Scopes              []string `json:"scopes"`
+	State               string   `json:"state,omitempty"`
+	Nonce               string   `json:"nonce,omitempty"`
+	CodeChallenge       string   `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string   `json:"code_challenge_method,omitempty"`
+	Prompt              string   `json:"prompt,omitempty"`
+	// MaxAge is the requested max_age in seconds; nil when absent.
+	MaxAge    *int64   `json:"max_age,omitempty"`
+	ACRValues []string `json:"acr_values,omitempty"`
+	// RequiredACR is the weakest ACR the login must reach, combining
+	// acr_values with the minimums of the requested scopes.
+	RequiredACR string `json:"required_acr,omitempty"`
+	// LoginMethods are the primary sign-in methods the client offers.
+	LoginMethods []models.LoginMethod `json:"login_methods,omitempty"`
+}
+
+// Authentication describes the end-user authentication behind a grant.
+// SessionID links issued tokens to the IdP session for logout.
+type Authentication struct {
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID string    `json:"sid,omitempty"`
+	AuthTime  time.Time `json:"auth_time"`
+	AMR       []string  `json:"amr,omitempty"`
+	ACR       string    `json:"acr,omitempty"`
+}
+
+// AuthorizationCode is the server-side record behind an issued code.
+type AuthorizationCode struct {
+	ClientID            string    `json:"client_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	Scopes              []string  `json:"scopes"`
+	Nonce               string    `json:"nonce,omitempty"`
+	CodeChallenge       string    `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string    `json:"code_challenge_method,omitempty"`
+	IssuedAt            time.Time `json:"issued_at"`
+	Authentication
+}
+
+type AuthorizationService interface {
+	ValidateRequest(ctx context.Context, params AuthorizeParams) (*AuthorizationRequest, error)
+	SaveLoginState(ctx context.Context, req *AuthorizationRequest) (string, error)
+	GetLoginState(ctx context.Context, id string) (*AuthorizationRequest, error)
+	ConsumeLoginState(ctx context.Context, id string) (*AuthorizationRequest, error)
+	IssueCode(ctx context.Context, req *AuthorizationRequest, auth Authentication) (string, error)
+	ConsumeCode(ctx context.Context, code string) (*AuthorizationCode, error)
+}
+
+type authorizationService struct {
+	clients repositories.ClientRepository
+	cache   CacheService
+	cfg     config.SSOConfig
+}
+
+func NewAuthorizationService(clients repositories.ClientRepository, cache CacheService, cfg config.SSOConfig) AuthorizationService {
+	return &authorizationService{clients: clients, cache: cache, cfg: cfg}
+}
+
+func (s *authorizationService) ValidateRequest(ctx context.Context, params AuthorizeParams) (*AuthorizationRequest, error) {
+	if params.ClientID == "" {
+		return nil, ErrInvalidAuthorizeClient
+	}
+
+	client, err := s.clients.GetByClientID(ctx, params.ClientID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrClientNotFound) {
+			return nil, ErrInvalidAuthorizeClient
+		}
+		return nil, err
+	}
+
+	if !containsString(client.RedirectURIs, params.RedirectURI) {
+		return nil, ErrRedirectURIMismatch
+	}
+
+	req := &AuthorizationRequest{
+		ClientID:    client.ClientID,
+		RedirectURI: params.RedirectURI,
+		State:       params.State,
+		Nonce:       params.Nonce,
+		Prompt:      params.Prompt,
+	}
+	req.LoginMethods = ClientLoginMethods(client)
+
+	if params.ResponseType != "code" {
+		return req, newOAuthError(OAuthErrorUnsupportedResponseType, "only response_type=code is supported")
+	}
+
+	scopes, err := s.resolveScopes(client, params.Scope)
+	if err != nil {
+		return req, err
+	}
+	req.Scopes = scopes
+
+	if params.MaxAge != "" {
+		maxAge, err := strconv.ParseInt(params.MaxAge, 10, 64)
+		if err != nil || maxAge < 0 {
+			return req, newOAuthError(OAuthErrorInvalidRequest, "max_age must be a non-negative integer")
+		}
+		req.MaxAge = &maxAge
+	}
+	req.ACRValues = strings.Fields(params.ACRValues)
+	req.RequiredACR = requiredACR(req.ACRValues, req.Scopes, s.cfg.ScopeMinimumACR)
+
+	if params.CodeChallenge == "" {
+		if s.cfg.PKCERequired || client.Type == models.ClientTypePublic {
+			return req, newOAuthError(OAuthErrorInvalidRequest, "code_challenge is required")
+		}
+		if params.CodeChallengeMethod != "" {
+			return req, newOAuthError(OAuthErrorInvalidRequest, "code_challenge_method without code_challenge")
+		}
+		return req, nil
+	}
+
+	if params.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return req, newOAuthError(OAuthErrorInvalidRequest, "code_challenge_method must be S256")
+	}
+	if !isValidPKCEValue(params.CodeChallenge, 43, 43) {
+		return req, newOAuthError(OAuthErrorInvalidRequest, "malformed code_challenge")
+	}
+
+	req.CodeChallenge = params.CodeChallenge
+	req.CodeChallengeMethod = params.CodeChallengeMethod
+
+	return req, nil
+}
+
+func (s *authorizationService) SaveLoginState(ctx context.Context, req *AuthorizationRequest) (string, error) {
+	id, err := utils.GenerateRandomToken(loginStateEntropyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.cache.SetJSON(ctx, loginStateKeyPrefix+id, req, s.cfg.LoginStateTTL); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// GetLoginState reads a parked request without consuming it, e.g. to check
+// which login methods its client offers.
+func (s *authorizationService) GetLoginState(ctx context.Context, id string) (*AuthorizationRequest, error) {
+	if id == "" {
+		return nil, ErrLoginStateNotFound
+	}
+
+	var req AuthorizationRequest
+	ok, err := s.cache.GetJSON(ctx, loginStateKeyPrefix+id, &req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLoginStateNotFound
+	}
+
+	return &req, nil
+}
+
+func (s *authorizationService) ConsumeLoginState(ctx context.Context, id string) (*AuthorizationRequest, error) {
+	if id == "" {
+		return nil, ErrLoginStateNotFound
+	}
+
+	var req AuthorizationRequest
+	ok, err := s.cache.GetAndDeleteJSON(ctx, loginStateKeyPrefix+id, &req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLoginStateNotFound
+	}
+
+	return &req, nil
+}
+
+func (s *authorizationService) IssueCode(ctx context.Context, req *AuthorizationRequest, auth Authentication) (string, error) {
+	code, err := utils.GenerateRandomToken(authorizationCodeEntropyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	auth.AuthTime = auth.AuthTime.UTC()
+	record := AuthorizationCode{
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scopes:              req.Scopes,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		IssuedAt:            time.Now().UTC(),
+		Authentication:      auth,
+	}
+
+	if err := s.cache.SetJSON(ctx, authorizationCodeKey(code), record, s.cfg.Tokens.AuthorizationCode); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ConsumeCode redeems a code exactly once; any later attempt reports
+// ErrAuthorizationCodeNotFound.
+func (s *authorizationService) ConsumeCode(ctx context.Context, code string) (*AuthorizationCode, error) {
+	if code == "" {
+		return nil, ErrAuthorizationCodeNotFound
+	}
+
+	var record AuthorizationCode
+	ok, err := s.cache.GetAndDeleteJSON(ctx, authorizationCodeKey(code), &record)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAuthorizationCodeNotFound
+	}
+
+	return &record, nil
+}
+
+// resolveScopes returns the requested scopes, falling back to the configured
+// defaults, and rejects anything the client is not registered for.
+func (s *authorizationService) resolveScopes(client *models.Client, requested string) ([]string, error) {
+	allowed := client.Scopes
+	if len(allowed) == 0 {
+		allowed = s.cfg.DefaultScopes
+	}
+
+	scopes := sanitizeScopes(strings.Fields(requested))
+	if len(scopes) == 0 {
+		for _, scope := range s.cfg.DefaultScopes {
+			if containsString(allowed, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes, nil
+	}
+
+	for _, scope := range scopes {
+		if !containsString(allowed, scope) || !userGrantable(scope) {
+			return nil, newOAuthError(OAuthErrorInvalidScope, "scope "+scope+" is not allowed for this client")
+		}
+	}
+
+	return scopes, nil
+}
+
+// authorizationCodeKey stores codes under their digest so the cache never
+// holds a redeemable value.
+func authorizationCodeKey(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return authorizationCodeKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// isValidPKCEValue checks the RFC 7636 unreserved character set and length.
+func isValidPKCEValue(value string, minLen, maxLen int) bool {
+	if len(value) < minLen || len(value) > maxLen {
+		return false
+	}
+
+	for _, r := range value {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+const (
+	BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+	logoutTokenTTL            = 2 * time.Minute
+	backchannelPollInterval   = 2 * time.Second
+	backchannelBatchSize      = 20
+	backchannelLease          = time.Minute
+	backchannelMaxAttempts    = 8
+	backchannelInitialBackoff = 5 * time.Second
+	backchannelMaxBackoff     = 10 * time.Minute
+	backchannelRequestTimeout = 5 * time.Second
+	backchannelPurgeInterval  = 10 * time.Minute
+	backchannelRetention      = 7 * 24 * time.Hour
+)
+
+// LogoutTokenClaims follow OIDC Back-Channel Logout 1.0 section 2.4.
+type LogoutTokenClaims struct {
+	Issuer    string              `json:"iss"`
+	Audience  string              `json:"aud"`
+	IssuedAt  int64               `json:"iat"`
+	ExpiresAt int64               `json:"exp"`
+	JWTID     string              `json:"jti"`
+	Subject   string              `json:"sub,omitempty"`
+	SessionID string              `json:"sid"`
+	Events    map[string]struct{} `json:"events"`
+}
+
+// BackchannelLogoutDispatcher queues logout notifications in Postgres so they
+// survive restarts, and delivers them with retries.
+type BackchannelLogoutDispatcher interface {
+	Enqueue(ctx context.Context, client *models.Client, subject, sessionID string) error
+	Run(ctx context.Context)
+}
+
+type backchannelLogoutDispatcher struct {
+	repo       repositories.BackchannelLogoutRepository
+	keys       keys.Manager
+	httpClient *http.Client
+	issuer     string
+}
+
+func NewBackchannelLogoutDispatcher(repo repositories.BackchannelLogoutRepository, keys keys.Manager, cfg config.SSOConfig) BackchannelLogoutDispatcher {
+	return &backchannelLogoutDispatcher{
+		repo: repo,
+		keys: keys,
+		httpClient: &http.Client{
+			Timeout: backchannelRequestTimeout,
+			// A logout endpoint answering with a redirect is misconfigured;
+			// never follow it with a valid logout token attached.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		issuer: strings.TrimRight(cfg.IssuerURL, "/"),
+	}
+}
+
+// Enqueue records a delivery for client. Clients without a back-channel
+// logout URI are skipped.
+func (d *backchannelLogoutDispatcher) Enqueue(ctx context.Context, client *models.Client, subject, sessionID string) error {
+	if client.BackchannelLogoutURI == "" {
+		return nil
+	}
+
+	return d.repo.Create(ctx, &models.BackchannelLogoutDelivery{
+		ID:            uuid.New(),
+		ClientID:      client.ClientID,
+		URI:           client.BackchannelLogoutURI,
+		Subject:       subject,
+		SessionID:     sessionID,
+		Status:        models.BackchannelLogoutStatusPending,
+		NextAttemptAt: time.Now().UTC(),
+	})
+}
+
+// Run polls for due deliveries until ctx is cancelled. Several instances may
+// run side by side; claimed deliveries are leased to one of them.
+func (d *backchannelLogoutDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(backchannelPollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		// Keep draining while batches come back full.
+		for {
+			if d.deliverBatch(ctx) < backchannelBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= backchannelPurgeInterval {
+			d.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverBatch posts one batch of due deliveries concurrently and returns how
+// many it claimed.
+func (d *backchannelLogoutDispatcher) deliverBatch(ctx context.Context) int {
+	deliveries, err := d.repo.ClaimDue(ctx, time.Now().UTC(), backchannelBatchSize, backchannelLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to claim back-channel logout deliveries: %v", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *models.BackchannelLogoutDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+func (d *backchannelLogoutDispatcher) deliver(ctx context.Context, delivery *models.BackchannelLogoutDelivery) {
+	err := d.post(ctx, delivery)
+
+	now := time.Now().UTC()
+	attempts := delivery.Attempts + 1
+
+	switch {
+	case err == nil:
+		err = d.repo.MarkDelivered(ctx, delivery.ID, now)
+	case attempts >= backchannelMaxAttempts:
+		log.Printf("back-channel logout to client %s failed permanently after %d attempts: %v", delivery.ClientID, attempts, err)
+		err = d.repo.MarkFailed(ctx, delivery.ID, attempts, err.Error())
+	default:
+		err = d.repo.MarkRetry(ctx, delivery.ID, attempts, now.Add(backchannelBackoff(attempts)), err.Error())
+	}
+
+	// The lease expires on its own, so a failed status update only means the
+	// client may be notified twice, which logout tokens allow for.
+	if err != nil {
+		log.Printf("failed to update back-channel logout delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// post signs a fresh logout token and sends it to the client.
+func (d *backchannelLogoutDispatcher) post(ctx context.Context, delivery *models.BackchannelLogoutDelivery) error {
+	now := time.Now().UTC()
+	token, err := d.keys.Sign(ctx, LogoutTokenClaims{
+		Issuer:    d.issuer,
+		Audience:  delivery.ClientID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(logoutTokenTTL).Unix(),
+		JWTID:     uuid.NewString(),
+		Subject:   delivery.Subject,
+		SessionID: delivery.SessionID,
+		Events:    map[string]struct{}{BackchannelLogoutEvent: {}},
+	})
+	if err != nil {
+		return err
+	}
+
+	body := url.Values{"logout_token": {token}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URI, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// purge drops finished deliveries past their retention window.
+func (d *backchannelLogoutDispatcher) purge(ctx context.Context) {
+	if _, err := d.repo.Purge(ctx, time.Now().UTC().Add(-backchannelRetention)); err != nil && ctx.Err() == nil {
+		log.Printf("failed to purge back-channel logout deliveries: %v", err)
+	}
+}
+
+// backchannelBackoff doubles the delay after every failed attempt.
+func backchannelBackoff(attempts int) time.Duration {
+	delay := backchannelInitialBackoff
+	for i := 1; i < attempts && delay < backchannelMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, backchannelMaxBackoff)
+}
@@ -11,12 +11,41 @@ import (
 // CacheService provides helpers for interacting with Redis as a cache backend.
 type CacheService interface {
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
 	SetJSON(ctx context.Context, key string, value any, ttl time.Duration) error
 	Get(ctx context.Context, key string) ([]byte, bool, error)
 	GetJSON(ctx context.Context, key string, dest any) (bool, error)
+	GetAndDelete(ctx context.Context, key string) ([]byte, bool, error)
+	GetAndDeleteJSON(ctx context.Context, key string, dest any) (bool, error)
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
 	Delete(ctx context.Context, key string) error
+
+	HashSet(ctx context.Context, key string, fields map[string]string, ttl time.Duration) error
+	HashSetIfExists(ctx context.Context, key string, fields map[string]string, ttl time.Duration) (bool, error)
+	HashGetAll(ctx context.Context, key string) (map[string]string, error)
+	HashGetAllAndDelete(ctx context.Context, key string) (map[string]string, error)
+
+	SetAdd(ctx context.Context, key, member string, ttl time.Duration) error
+	SetRemove(ctx context.Context, key string, members ...string) error
+	SetMembers(ctx context.Context, key string) ([]string, error)
 }
 
+// hashSetIfExistsScript updates fields of a hash only while it exists, so a
+// late write can never bring back a deleted hash. A positive TTL in
+// milliseconds replaces the expiry.
+var hashSetIfExistsScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call("HSET", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+if tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
 type redisCacheService struct {
 	client redis.Cmdable
 }
@@ -30,6 +59,12 @@ func (s *redisCacheService) Set(ctx context.Context, key string, value []byte, t
 	return s.client.Set(ctx, key, value, ttl).Err()
 }
 
+// SetIfAbsent stores value only when key does not exist yet and reports
+// whether it did. It is the building block for simple rate limits.
+func (s *redisCacheService) SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, value, ttl).Result()
+}
+
 func (s *redisCacheService) SetJSON(ctx context.Context, key string, value any, ttl time.Duration) error {
 	payload, err := json.Marshal(value)
 	if err != nil {
@@ -64,6 +99,122 @@ func (s *redisCacheService) GetJSON(ctx context.Context, key string, dest any) (
 	return true, nil
 }
 
+// GetAndDelete atomically reads and removes key, so concurrent callers can
+// never observe the same value twice.
+func (s *redisCacheService) GetAndDelete(ctx context.Context, key string) ([]byte, bool, error) {
+	result, err := s.client.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return result, true, nil
+}
+
+func (s *redisCacheService) GetAndDeleteJSON(ctx context.Context, key string, dest any) (bool, error) {
+	payload, ok, err := s.GetAndDelete(ctx, key)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	if err := json.Unmarshal(payload, dest); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Increment atomically adds one to the counter at key and returns the new
+// value. Every increment pushes the expiry out to ttl again.
+func (s *redisCacheService) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
 func (s *redisCacheService) Delete(ctx context.Context, key string) error {
 	return s.client.Del(ctx, key).Err()
 }
+
+// HashSet writes fields to the hash at key and sets its expiry to ttl.
+func (s *redisCacheService) HashSet(ctx context.Context, key string, fields map[string]string, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields)
+		pipe.PExpire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// HashSetIfExists writes fields only if the hash at key still exists and
+// reports whether it did. A ttl of zero keeps the current expiry.
+func (s *redisCacheService) HashSetIfExists(ctx context.Context, key string, fields map[string]string, ttl time.Duration) (bool, error) {
+	args := make([]any, 0, 1+2*len(fields))
+	args = append(args, ttl.Milliseconds())
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+
+	updated, err := hashSetIfExistsScript.Run(ctx, s.client, []string{key}, args...).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return updated == 1, nil
+}
+
+// HashGetAll returns the fields of the hash at key; empty when it is absent.
+func (s *redisCacheService) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return s.client.HGetAll(ctx, key).Result()
+}
+
+// HashGetAllAndDelete atomically reads and removes the hash at key.
+func (s *redisCacheService) HashGetAllAndDelete(ctx context.Context, key string) (map[string]string, error) {
+	var fields *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fields.Val(), nil
+}
+
+// SetAdd adds member to the set at key and pushes its expiry out to ttl.
+func (s *redisCacheService) SetAdd(ctx context.Context, key, member string, ttl time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, key, member)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *redisCacheService) SetRemove(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	values := make([]any, 0, len(members))
+	for _, member := range members {
+		values = append(values, member)
+	}
+	return s.client.SRem(ctx, key, values...).Err()
+}
+
+func (s *redisCacheService) SetMembers(ctx context.Context, key string) ([]string, error) {
+	return s.client.SMembers(ctx, key).Result()
+}
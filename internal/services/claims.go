@@ -0,0 +1,67 @@
+package services
+
+import (
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+
+	// ScopeAdmin grants access to the administrative API. It is only ever
+	// issued through client_credentials to clients registered with it, never
+	// on behalf of an end user.
+	ScopeAdmin = "passport:admin"
+)
+
+// userGrantable reports whether scope may be granted on behalf of an end
+// user, as opposed to only to a client acting for itself.
+func userGrantable(scope string) bool {
+	return scope != ScopeAdmin
+}
+
+// SupportedUserClaims lists every claim UserClaims can release.
+var SupportedUserClaims = []string{
+	"sub", "email", "email_verified", "name", "given_name", "family_name", "locale", "updated_at",
+}
+
+// UserClaims are the end-user claims released for a set of granted scopes.
+// The ID token and /userinfo both build on it so they never disagree.
+type UserClaims struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+	Locale        string `json:"locale,omitempty"`
+	UpdatedAt     int64  `json:"updated_at,omitempty"`
+}
+
+// UserInfo is the /userinfo response body.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	UserClaims
+}
+
+// ReleaseUserClaims maps user to the claims allowed by scopes: email claims
+// need the email scope and profile claims need the profile scope.
+func ReleaseUserClaims(user *models.User, scopes []string) UserClaims {
+	var claims UserClaims
+
+	if containsString(scopes, ScopeEmail) {
+		verified := user.EmailVerified
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+
+	if containsString(scopes, ScopeProfile) {
+		claims.Name = user.Name
+		claims.GivenName = user.GivenName
+		claims.FamilyName = user.FamilyName
+		claims.Locale = user.Locale
+		claims.UpdatedAt = user.UpdatedAt.Unix()
+	}
+
+	return claims
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
@@ -20,15 +21,30 @@ var (
 	ErrInvalidClientType   = errors.New("invalid client type")
 	ErrMissingRedirectURIs = errors.New("at least one redirect uri is required")
 	ErrInvalidRedirectURI  = errors.New("redirect uri must be absolute")
+	ErrInvalidLogoURI      = errors.New("logo uri must be an absolute http or https uri")
+	ErrInvalidLogoutURI    = errors.New("logout uri must be absolute")
 	ErrClientIsPublic      = errors.New("client is public and does not have a secret")
+	ErrInvalidAuthMethod   = errors.New("invalid token endpoint auth method")
+	ErrInvalidLoginMethod  = errors.New("invalid login method")
+
+	ErrInvalidClientCredentials = errors.New("invalid client credentials")
 )
 
 type CreateClientParams struct {
 	Name                   string
+	LogoURI                string
 	Type                   models.ClientType
+	AuthMethod             models.ClientAuthMethod
 	RedirectURIs           []string
 	PostLogoutRedirectURIs []string
-	Scopes                 []string
+	BackchannelLogoutURI   string
+	FrontchannelLogoutURI  string
+	// FrontchannelLogoutSessionRequired makes the front-channel logout
+	// request carry iss and sid.
+	FrontchannelLogoutSessionRequired bool
+	Scopes                            []string
+	LoginMethods                      []models.LoginMethod
+	AllowedAudiences                  []string
 }
 
 type CreateClientResult struct {
@@ -41,6 +57,14 @@ type RotateClientSecretResult struct {
 	PlainSecret string
 }
 
+// ClientCredentials are the credentials a client presented at the token
+// endpoint and the method it used to present them.
+type ClientCredentials struct {
+	ClientID string
+	Secret   string
+	Method   models.ClientAuthMethod
+}
+
 type ListClientsFilter struct {
 	Offset int
 	Limit  int
@@ -52,6 +76,7 @@ type ClientService interface {
 	GetClientByClientID(ctx context.Context, clientID string) (*models.Client, error)
 	ListClients(ctx context.Context, filter ListClientsFilter) ([]models.Client, error)
 	RotateClientSecret(ctx context.Context, id uuid.UUID) (*RotateClientSecretResult, error)
+	AuthenticateClient(ctx context.Context, creds ClientCredentials) (*models.Client, error)
 }
 
 type clientService struct {
@@ -75,6 +100,11 @@ func (s *clientService) CreateClient(ctx context.Context, params CreateClientPar
 		return nil, ErrInvalidClientType
 	}
 
+	authMethod, err := resolveAuthMethod(clientType, params.AuthMethod)
+	if err != nil {
+		return nil, err
+	}
+
 	redirectURIs, err := normalizeURIList(params.RedirectURIs, true)
 	if err != nil {
 		return nil, err
@@ -85,6 +115,26 @@ func (s *clientService) CreateClient(ctx context.Context, params CreateClientPar
 		return nil, err
 	}
 
+	backchannelLogoutURI, err := normalizeLogoutURI(params.BackchannelLogoutURI)
+	if err != nil {
+		return nil, err
+	}
+
+	frontchannelLogoutURI, err := normalizeLogoutURI(params.FrontchannelLogoutURI)
+	if err != nil {
+		return nil, err
+	}
+
+	logoURI, err := normalizeLogoURI(params.LogoURI)
+	if err != nil {
+		return nil, err
+	}
+
+	loginMethods, err := normalizeLoginMethods(params.LoginMethods)
+	if err != nil {
+		return nil, err
+	}
+
 	var secretHash *string
 	var plainSecret *string
 
@@ -103,14 +153,21 @@ func (s *clientService) CreateClient(ctx context.Context, params CreateClientPar
 	}
 
 	client := &models.Client{
-		ID:                     uuid.New(),
-		ClientID:               uuid.NewString(),
-		Name:                   strings.TrimSpace(params.Name),
-		Type:                   clientType,
-		SecretHash:             secretHash,
-		RedirectURIs:           redirectURIs,
-		PostLogoutRedirectURIs: postLogoutURIs,
-		Scopes:                 sanitizeScopes(params.Scopes),
+		ID:                                uuid.New(),
+		ClientID:                          uuid.NewString(),
+		Name:                              strings.TrimSpace(params.Name),
+		LogoURI:                           logoURI,
+		Type:                              clientType,
+		SecretHash:                        secretHash,
+		TokenEndpointAuthMethod:           authMethod,
+		RedirectURIs:                      redirectURIs,
+		PostLogoutRedirectURIs:            postLogoutURIs,
+		BackchannelLogoutURI:              backchannelLogoutURI,
+		FrontchannelLogoutURI:             frontchannelLogoutURI,
+		FrontchannelLogoutSessionRequired: params.FrontchannelLogoutSessionRequired,
+		Scopes:                            sanitizeScopes(params.Scopes),
+		LoginMethods:                      loginMethods,
+		AllowedAudiences:                  sanitizeScopes(params.AllowedAudiences),
 	}
 
 	if client.RedirectURIs == nil {
@@ -122,6 +179,9 @@ func (s *clientService) CreateClient(ctx context.Context, params CreateClientPar
 	if client.Scopes == nil {
 		client.Scopes = []string{}
 	}
+	if client.AllowedAudiences == nil {
+		client.AllowedAudiences = []string{}
+	}
 
 	if err := s.repo.Create(ctx, client); err != nil {
 		return nil, err
@@ -181,6 +241,76 @@ func (s *clientService) RotateClientSecret(ctx context.Context, id uuid.UUID) (*
 	return &RotateClientSecretResult{Client: client, PlainSecret: secretValue}, nil
 }
 
+// AuthenticateClient verifies the credentials presented at the token endpoint
+// using the auth method the client registered. Public clients authenticate
+// with their client_id alone.
+func (s *clientService) AuthenticateClient(ctx context.Context, creds ClientCredentials) (*models.Client, error) {
+	if creds.ClientID == "" {
+		return nil, ErrInvalidClientCredentials
+	}
+
+	client, err := s.repo.GetByClientID(ctx, creds.ClientID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrClientNotFound) {
+			return nil, ErrInvalidClientCredentials
+		}
+		return nil, err
+	}
+
+	if creds.Method != client.TokenEndpointAuthMethod {
+		return nil, ErrInvalidClientCredentials
+	}
+
+	if client.TokenEndpointAuthMethod == models.ClientAuthMethodNone {
+		if client.Type != models.ClientTypePublic {
+			return nil, ErrInvalidClientCredentials
+		}
+		return client, nil
+	}
+
+	if client.SecretHash == nil || creds.Secret == "" {
+		return nil, ErrInvalidClientCredentials
+	}
+
+	match, needsRehash, err := utils.VerifySensitiveValue(creds.Secret, *client.SecretHash)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, ErrInvalidClientCredentials
+	}
+
+	if needsRehash {
+		if hash, err := utils.HashSensitiveValue(creds.Secret); err == nil {
+			if err := s.repo.UpdateSecret(ctx, client.ID, &hash); err == nil {
+				client.SecretHash = &hash
+			}
+		}
+	}
+
+	return client, nil
+}
+
+// resolveAuthMethod defaults the token endpoint auth method from the client
+// type and rejects combinations that cannot work.
+func resolveAuthMethod(clientType models.ClientType, method models.ClientAuthMethod) (models.ClientAuthMethod, error) {
+	if clientType == models.ClientTypePublic {
+		if method != "" && method != models.ClientAuthMethodNone {
+			return "", ErrInvalidAuthMethod
+		}
+		return models.ClientAuthMethodNone, nil
+	}
+
+	switch method {
+	case "":
+		return models.ClientAuthMethodSecretBasic, nil
+	case models.ClientAuthMethodSecretBasic, models.ClientAuthMethodSecretPost:
+		return method, nil
+	default:
+		return "", ErrInvalidAuthMethod
+	}
+}
+
 func isValidClientType(clientType models.ClientType) bool {
 	switch clientType {
 	case models.ClientTypePublic, models.ClientTypeConfidential:
@@ -230,6 +360,68 @@ func normalizeURIList(values []string, required bool) ([]string, error) {
 	return normalized, nil
 }
 
+// normalizeLogoutURI validates an optional logout notification endpoint.
+func normalizeLogoutURI(value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return "", nil
+	}
+
+	parsed, err := url.Parse(trimmed)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+		return "", ErrInvalidLogoutURI
+	}
+
+	return trimmed, nil
+}
+
+// normalizeLogoURI accepts an empty value or an absolute web URI; the logo is
+// embedded in emails, so other schemes are refused.
+func normalizeLogoURI(value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return "", nil
+	}
+
+	parsed, err := url.Parse(trimmed)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return "", ErrInvalidLogoURI
+	}
+
+	return trimmed, nil
+}
+
+// isAuthenticatedConfidential reports whether client is confidential and
+// proved it with a secret. A confidential client registered with the none
+// method is treated as unauthenticated.
+func isAuthenticatedConfidential(client *models.Client) bool {
+	return client.Type == models.ClientTypeConfidential && client.TokenEndpointAuthMethod != models.ClientAuthMethodNone
+}
+
+// ClientLoginMethods returns the primary sign-in methods offered for client.
+func ClientLoginMethods(client *models.Client) []models.LoginMethod {
+	if len(client.LoginMethods) == 0 {
+		return models.DefaultLoginMethods
+	}
+	return client.LoginMethods
+}
+
+// normalizeLoginMethods rejects unknown methods and drops duplicates.
+func normalizeLoginMethods(methods []models.LoginMethod) ([]models.LoginMethod, error) {
+	clean := make([]models.LoginMethod, 0, len(methods))
+	for _, method := range methods {
+		switch method {
+		case models.LoginMethodPassword, models.LoginMethodPasskey, models.LoginMethodEmailLink, models.LoginMethodEmailCode:
+		default:
+			return nil, ErrInvalidLoginMethod
+		}
+		if !slices.Contains(clean, method) {
+			clean = append(clean, method)
+		}
+	}
+	return clean, nil
+}
+
 func sanitizeScopes(scopes []string) []string {
 	if len(scopes) == 0 {
 		return nil
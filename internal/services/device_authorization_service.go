@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeEntropyBytes = 32
+	userCodeLength         = 8
+	// userCodeAlphabet avoids vowels and look-alike characters (RFC 8628 6.1).
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	slowDownStep     = 5 * time.Second
+
+	// userCodeMaxAttempts caps user code lookups per source IP within one
+	// device code lifetime, so codes cannot be enumerated on /device.
+	userCodeMaxAttempts = 20
+
+	deviceCodeKeyPrefix       = "device_code:"
+	deviceDecisionKeyPrefix   = "device_decision:"
+	devicePollKeyPrefix       = "device_poll:"
+	userCodeKeyPrefix         = "device_user_code:"
+	userCodeAttemptsKeyPrefix = "device_user_code_attempts:"
+)
+
+var (
+	ErrUserCodeNotFound     = errors.New("user code is invalid or expired")
+	ErrDeviceCodeNotFound   = errors.New("device code is invalid")
+	ErrDeviceCodeNotPending = errors.New("device authorization was already decided")
+	ErrUserCodeRateLimited  = errors.New("too many user code attempts, try again later")
+)
+
+type DeviceAuthorizationStatus string
+
+const (
+	DeviceAuthorizationPending  DeviceAuthorizationStatus = "pending"
+	DeviceAuthorizationApproved DeviceAuthorizationStatus = "approved"
+	DeviceAuthorizationDenied   DeviceAuthorizationStatus = "denied"
+)
+
+// DeviceAuthorization is the server-side state shared by a device_code and
+// its user_code. The stored record never changes after Start; the decision
+// and the polling state live under their own keys so the user approving and
+// the device polling cannot overwrite each other.
+type DeviceAuthorization struct {
+	DeviceCodeHash string                    `json:"device_code_hash"`
+	UserCode       string                    `json:"user_code"`
+	ClientID       string                    `json:"client_id"`
+	Scopes         []string                  `json:"scopes"`
+	Status         DeviceAuthorizationStatus `json:"status"`
+	Interval       time.Duration             `json:"interval"`
+	ExpiresAt      time.Time                 `json:"expires_at"`
+	Authentication
+}
+
+type deviceDecision struct {
+	Status DeviceAuthorizationStatus `json:"status"`
+	Authentication
+}
+
+type devicePollState struct {
+	Interval     time.Duration `json:"interval"`
+	LastPolledAt time.Time     `json:"last_polled_at"`
+}
+
+// DeviceAuthorizationResponse is the RFC 8628 section 3.2 response.
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type DeviceAuthorizationService interface {
+	Start(ctx context.Context, client *models.Client, scope string) (*DeviceAuthorizationResponse, error)
+	// LimitAttempts counts a user code attempt from ip and returns
+	// ErrUserCodeRateLimited once the source has used up its attempts.
+	LimitAttempts(ctx context.Context, ip string) error
+	Lookup(ctx context.Context, userCode string) (*DeviceAuthorization, error)
+	Approve(ctx context.Context, userCode string, auth Authentication) error
+	Deny(ctx context.Context, userCode string) error
+	Poll(ctx context.Context, clientID, deviceCode string) (*DeviceAuthorization, error)
+}
+
+type deviceAuthorizationService struct {
+	cache CacheService
+	cfg   config.SSOConfig
+}
+
+func NewDeviceAuthorizationService(cache CacheService, cfg config.SSOConfig) DeviceAuthorizationService {
+	return &deviceAuthorizationService{cache: cache, cfg: cfg}
+}
+
+func (s *deviceAuthorizationService) Start(ctx context.Context, client *models.Client, scope string) (*DeviceAuthorizationResponse, error) {
+	scopes := sanitizeScopes(strings.Fields(scope))
+	allowed := client.Scopes
+	if len(allowed) == 0 {
+		allowed = s.cfg.DefaultScopes
+	}
+	if len(scopes) == 0 {
+		for _, candidate := range allowed {
+			if userGrantable(candidate) {
+				scopes = append(scopes, candidate)
+			}
+		}
+	}
+	for _, requested := range scopes {
+		if !containsString(allowed, requested) || !userGrantable(requested) {
+			return nil, newOAuthError(OAuthErrorInvalidScope, "scope "+requested+" is not allowed for this client")
+		}
+	}
+
+	deviceCode, err := utils.GenerateRandomToken(deviceCodeEntropyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	record := &DeviceAuthorization{
+		DeviceCodeHash: hashDeviceCode(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		Scopes:         scopes,
+		Status:         DeviceAuthorizationPending,
+		Interval:       s.cfg.DeviceCodePollInterval,
+		ExpiresAt:      now.Add(s.cfg.DeviceCodeTTL),
+	}
+
+	if err := s.cache.SetJSON(ctx, deviceCodeKeyPrefix+record.DeviceCodeHash, record, s.ttl(record)); err != nil {
+		return nil, err
+	}
+	if err := s.cache.Set(ctx, userCodeKeyPrefix+userCode, []byte(record.DeviceCodeHash), s.cfg.DeviceCodeTTL); err != nil {
+		return nil, err
+	}
+
+	verificationURI := strings.TrimRight(s.cfg.IssuerURL, "/") + "/device"
+
+	return &DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + formatUserCode(userCode),
+		ExpiresIn:               int64(s.cfg.DeviceCodeTTL.Seconds()),
+		Interval:                int64(s.cfg.DeviceCodePollInterval.Seconds()),
+	}, nil
+}
+
+func (s *deviceAuthorizationService) LimitAttempts(ctx context.Context, ip string) error {
+	attempts, err := s.cache.Increment(ctx, userCodeAttemptsKeyPrefix+hashDeviceCode(ip), s.cfg.DeviceCodeTTL)
+	if err != nil {
+		return err
+	}
+	if attempts > userCodeMaxAttempts {
+		return ErrUserCodeRateLimited
+	}
+	return nil
+}
+
+func (s *deviceAuthorizationService) Lookup(ctx context.Context, userCode string) (*DeviceAuthorization, error) {
+	normalized := normalizeUserCode(userCode)
+	if normalized == "" {
+		return nil, ErrUserCodeNotFound
+	}
+
+	hash, ok, err := s.cache.Get(ctx, userCodeKeyPrefix+normalized)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrUserCodeNotFound
+	}
+
+	record, err := s.load(ctx, string(hash))
+	if err != nil {
+		if errors.Is(err, ErrDeviceCodeNotFound) {
+			return nil, ErrUserCodeNotFound
+		}
+		return nil, err
+	}
+	if !record.ExpiresAt.After(time.Now().UTC()) {
+		return nil, ErrUserCodeNotFound
+	}
+
+	decision, err := s.decision(ctx, record.DeviceCodeHash)
+	if err != nil {
+		return nil, err
+	}
+	if decision != nil {
+		record.Status = decision.Status
+	}
+
+	return record, nil
+}
+
+func (s *deviceAuthorizationService) Approve(ctx context.Context, userCode string, auth Authentication) error {
+	auth.AuthTime = auth.AuthTime.UTC()
+
+	return s.decide(ctx, userCode, deviceDecision{
+		Status:         DeviceAuthorizationApproved,
+		Authentication: auth,
+	})
+}
+
+func (s *deviceAuthorizationService) Deny(ctx context.Context, userCode string) error {
+	return s.decide(ctx, userCode, deviceDecision{Status: DeviceAuthorizationDenied})
+}
+
+// Poll implements the device side of RFC 8628 section 3.5. An approved
+// authorization is returned exactly once.
+func (s *deviceAuthorizationService) Poll(ctx context.Context, clientID, deviceCode string) (*DeviceAuthorization, error) {
+	if deviceCode == "" {
+		return nil, newOAuthError(OAuthErrorInvalidRequest, "device_code is required")
+	}
+
+	hash := hashDeviceCode(deviceCode)
+	record, err := s.load(ctx, hash)
+	if err != nil {
+		if errors.Is(err, ErrDeviceCodeNotFound) {
+			return nil, newOAuthError(OAuthErrorInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+
+	if record.ClientID != clientID {
+		return nil, newOAuthError(OAuthErrorInvalidGrant, "device_code was issued to another client")
+	}
+
+	now := time.Now().UTC()
+	if !record.ExpiresAt.After(now) {
+		return nil, newOAuthError(OAuthErrorExpiredToken, "")
+	}
+
+	// Taking the decision is the single step that redeems the device code,
+	// so concurrent polls cannot both receive an approval.
+	var decision deviceDecision
+	decided, err := s.cache.GetAndDeleteJSON(ctx, deviceDecisionKeyPrefix+hash, &decision)
+	if err != nil {
+		return nil, err
+	}
+	if decided {
+		if err := s.discard(ctx, record); err != nil {
+			return nil, err
+		}
+		if decision.Status != DeviceAuthorizationApproved {
+			return nil, newOAuthError(OAuthErrorAccessDenied, "")
+		}
+		record.Status = decision.Status
+		record.Authentication = decision.Authentication
+		return record, nil
+	}
+
+	state := devicePollState{Interval: record.Interval}
+	if _, err := s.cache.GetJSON(ctx, devicePollKeyPrefix+hash, &state); err != nil {
+		return nil, err
+	}
+
+	tooFast := !state.LastPolledAt.IsZero() && now.Sub(state.LastPolledAt) < state.Interval
+	if tooFast {
+		state.Interval += slowDownStep
+	}
+	state.LastPolledAt = now
+
+	if err := s.cache.SetJSON(ctx, devicePollKeyPrefix+hash, state, s.ttl(record)); err != nil {
+		return nil, err
+	}
+
+	if tooFast {
+		return nil, newOAuthError(OAuthErrorSlowDown, "")
+	}
+	return nil, newOAuthError(OAuthErrorAuthorizationPending, "")
+}
+
+// decide records the first decision for a user code; later ones fail with
+// ErrDeviceCodeNotPending.
+func (s *deviceAuthorizationService) decide(ctx context.Context, userCode string, decision deviceDecision) error {
+	record, err := s.Lookup(ctx, userCode)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.cache.SetIfAbsent(ctx, deviceDecisionKeyPrefix+record.DeviceCodeHash, payload, s.ttl(record))
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return ErrDeviceCodeNotPending
+	}
+
+	return nil
+}
+
+func (s *deviceAuthorizationService) decision(ctx context.Context, hash string) (*deviceDecision, error) {
+	var decision deviceDecision
+	ok, err := s.cache.GetJSON(ctx, deviceDecisionKeyPrefix+hash, &decision)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+// discard removes a redeemed or denied authorization.
+func (s *deviceAuthorizationService) discard(ctx context.Context, record *DeviceAuthorization) error {
+	for _, key := range []string{
+		deviceCodeKeyPrefix + record.DeviceCodeHash,
+		devicePollKeyPrefix + record.DeviceCodeHash,
+		userCodeKeyPrefix + record.UserCode,
+	} {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *deviceAuthorizationService) load(ctx context.Context, hash string) (*DeviceAuthorization, error) {
+	var record DeviceAuthorization
+	ok, err := s.cache.GetJSON(ctx, deviceCodeKeyPrefix+hash, &record)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDeviceCodeNotFound
+	}
+	return &record, nil
+}
+
+// ttl keeps the keys around for one extra TTL past expiry so polling clients
+// receive expired_token rather than invalid_grant.
+func (s *deviceAuthorizationService) ttl(record *DeviceAuthorization) time.Duration {
+	return time.Until(record.ExpiresAt) + s.cfg.DeviceCodeTTL
+}
+
+func generateUserCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		index, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[index.Int64()]
+	}
+	return string(code), nil
+}
+
+func formatUserCode(code string) string {
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts the code the way people type it: any case, with
+// or without separators.
+func normalizeUserCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() != userCodeLength {
+		return ""
+	}
+	return b.String()
+}
+
+func hashDeviceCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
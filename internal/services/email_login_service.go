@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	emailLoginLinkKeyPrefix     = "email_login_link:"
+	emailLoginCodeKeyPrefix     = "email_login_code:"
+	emailLoginRateKeyPrefix     = "email_login_rate:"
+	emailLoginAttemptsKeyPrefix = "email_login_attempts:"
+
+	emailLoginTokenEntropy = 32
+	emailLoginCodeDigits   = 6
+	emailLoginMaxAttempts  = 5
+
+	// EmailLoginPath is where sign-in links point; it doubles as the token
+	// audience so no other JWT from this issuer is accepted.
+	EmailLoginPath = "/login/email/verify"
+)
+
+var (
+	ErrEmailLoginInvalid      = errors.New("sign-in link or code is invalid or expired")
+	ErrEmailLoginRateLimited  = errors.New("a sign-in email was sent recently, try again later")
+	ErrUnsupportedEmailMethod = errors.New("unsupported email login method")
+)
+
+type StartEmailLoginParams struct {
+	Email string
+	// Method is models.LoginMethodEmailLink or models.LoginMethodEmailCode.
+	Method     models.LoginMethod
+	ClientID   string
+	LoginState string
+}
+
+// EmailLoginResult identifies the user behind a redeemed link or code and
+// the methods to record on the session.
+type EmailLoginResult struct {
+	UserID     uuid.UUID
+	LoginState string
+	AMR        []string
+}
+
+// emailLoginLinkClaims is the signed payload of a sign-in link. The jti is
+// recorded in Redis at issue time and deleted on use.
+type emailLoginLinkClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+	JWTID     string `json:"jti"`
+}
+
+type emailLoginLink struct {
+	UserID     uuid.UUID `json:"user_id"`
+	LoginState string    `json:"login_state,omitempty"`
+}
+
+// emailLoginCode is a pending code login. UserID is nil when the address has
+// no account that may sign in, so the caller sees the same flow either way.
+type emailLoginCode struct {
+	UserID     *uuid.UUID `json:"user_id,omitempty"`
+	CodeHash   string     `json:"code_hash"`
+	LoginState string     `json:"login_state,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+type EmailLoginService interface {
+	Start(ctx context.Context, params StartEmailLoginParams) (string, error)
+	VerifyLink(ctx context.Context, token string) (*EmailLoginResult, error)
+	VerifyCode(ctx context.Context, loginToken, code string) (*EmailLoginResult, error)
+}
+
+type emailLoginService struct {
+	users  repositories.UserRepository
+	keys   keys.Manager
+	cache  CacheService
+	emails EmailService
+	cfg    config.SSOConfig
+}
+
+func NewEmailLoginService(
+	users repositories.UserRepository,
+	keys keys.Manager,
+	cache CacheService,
+	emails EmailService,
+	cfg config.SSOConfig,
+) EmailLoginService {
+	return &emailLoginService{users: users, keys: keys, cache: cache, emails: emails, cfg: cfg}
+}
+
+// Start emails a sign-in link or code. For codes it returns the login token
+// the code must be presented with; links carry everything they need. Unknown
+// and disabled addresses get no email but an otherwise identical response.
+func (s *emailLoginService) Start(ctx context.Context, params StartEmailLoginParams) (string, error) {
+	if params.Method != models.LoginMethodEmailLink && params.Method != models.LoginMethodEmailCode {
+		return "", ErrUnsupportedEmailMethod
+	}
+
+	email := strings.TrimSpace(params.Email)
+	if email == "" {
+		return "", ErrEmailLoginInvalid
+	}
+
+	allowed, err := s.cache.SetIfAbsent(ctx, emailLoginRateKeyPrefix+utils.HashToken(strings.ToLower(email)), []byte("1"), s.cfg.EmailResendInterval)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", ErrEmailLoginRateLimited
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+		return "", err
+	}
+	if user != nil && user.Status == models.UserStatusDisabled {
+		user = nil
+	}
+
+	if params.Method == models.LoginMethodEmailLink {
+		if user == nil {
+			return "", nil
+		}
+		return "", s.sendLink(ctx, user, params)
+	}
+
+	return s.sendCode(ctx, user, params)
+}
+
+// VerifyLink redeems a sign-in link. Each link works once.
+func (s *emailLoginService) VerifyLink(ctx context.Context, token string) (*EmailLoginResult, error) {
+	if token == "" {
+		return nil, ErrEmailLoginInvalid
+	}
+
+	var claims emailLoginLinkClaims
+	if err := s.keys.Verify(ctx, token, &claims); err != nil {
+		if errors.Is(err, keys.ErrInvalidToken) || errors.Is(err, keys.ErrUnknownKey) {
+			return nil, ErrEmailLoginInvalid
+		}
+		return nil, err
+	}
+
+	if claims.Issuer != s.issuer() ||
+		claims.Audience != s.audience() ||
+		claims.JWTID == "" ||
+		time.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrEmailLoginInvalid
+	}
+
+	var link emailLoginLink
+	found, err := s.cache.GetAndDeleteJSON(ctx, emailLoginLinkKeyPrefix+claims.JWTID, &link)
+	if err != nil {
+		return nil, err
+	}
+	if !found || link.UserID.String() != claims.Subject {
+		return nil, ErrEmailLoginInvalid
+	}
+
+	return &EmailLoginResult{UserID: link.UserID, LoginState: link.LoginState, AMR: []string{AMREmail}}, nil
+}
+
+// VerifyCode checks a code against the login started with loginToken. The
+// login is dropped after too many wrong codes.
+func (s *emailLoginService) VerifyCode(ctx context.Context, loginToken, code string) (*EmailLoginResult, error) {
+	if loginToken == "" {
+		return nil, ErrEmailLoginInvalid
+	}
+
+	key := emailLoginCodeKeyPrefix + utils.HashToken(loginToken)
+
+	var pending emailLoginCode
+	found, err := s.cache.GetJSON(ctx, key, &pending)
+	if err != nil {
+		return nil, err
+	}
+	if !found || time.Now().After(pending.ExpiresAt) {
+		return nil, ErrEmailLoginInvalid
+	}
+
+	// The attempt is counted atomically before the code is compared, so
+	// parallel guesses cannot share one read of the counter.
+	attemptsKey := emailLoginAttemptsKeyPrefix + utils.HashToken(loginToken)
+	attempts, err := s.cache.Increment(ctx, attemptsKey, s.cfg.EmailLoginTTL)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > emailLoginMaxAttempts {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		return nil, ErrEmailLoginInvalid
+	}
+
+	expected := emailLoginCodeHash(loginToken, strings.TrimSpace(code))
+	if pending.UserID == nil || subtle.ConstantTimeCompare([]byte(expected), []byte(pending.CodeHash)) != 1 {
+		return nil, ErrEmailLoginInvalid
+	}
+
+	// GetAndDelete makes the code single use under concurrent requests.
+	_, consumed, err := s.cache.GetAndDelete(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrEmailLoginInvalid
+	}
+	if err := s.cache.Delete(ctx, attemptsKey); err != nil {
+		return nil, err
+	}
+
+	return &EmailLoginResult{UserID: *pending.UserID, LoginState: pending.LoginState, AMR: []string{AMREmail, AMROTP}}, nil
+}
+
+func (s *emailLoginService) sendLink(ctx context.Context, user *models.User, params StartEmailLoginParams) error {
+	now := time.Now().UTC()
+	jti := uuid.NewString()
+
+	token, err := s.keys.Sign(ctx, emailLoginLinkClaims{
+		Issuer:    s.issuer(),
+		Subject:   user.ID.String(),
+		Audience:  s.audience(),
+		ExpiresAt: now.Add(s.cfg.EmailLoginTTL).Unix(),
+		IssuedAt:  now.Unix(),
+		JWTID:     jti,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := emailLoginLink{UserID: user.ID, LoginState: params.LoginState}
+	if err := s.cache.SetJSON(ctx, emailLoginLinkKeyPrefix+jti, link, s.cfg.EmailLoginTTL); err != nil {
+		return err
+	}
+
+	return s.emails.Send(ctx, user, params.ClientID, mailer.TemplateLoginLink, map[string]any{
+		"Link":    s.audience() + "?" + url.Values{"token": {token}}.Encode(),
+		"Minutes": int(s.cfg.EmailLoginTTL.Minutes()),
+	})
+}
+
+func (s *emailLoginService) sendCode(ctx context.Context, user *models.User, params StartEmailLoginParams) (string, error) {
+	loginToken, err := utils.GenerateRandomToken(emailLoginTokenEntropy)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := generateEmailLoginCode()
+	if err != nil {
+		return "", err
+	}
+
+	pending := emailLoginCode{
+		CodeHash:   emailLoginCodeHash(loginToken, code),
+		LoginState: params.LoginState,
+		ExpiresAt:  time.Now().Add(s.cfg.EmailLoginTTL),
+	}
+	if user != nil {
+		pending.UserID = &user.ID
+	}
+
+	if err := s.cache.SetJSON(ctx, emailLoginCodeKeyPrefix+utils.HashToken(loginToken), pending, s.cfg.EmailLoginTTL); err != nil {
+		return "", err
+	}
+
+	if user != nil {
+		if err := s.emails.Send(ctx, user, params.ClientID, mailer.TemplateLoginCode, map[string]any{
+			"Code":    code,
+			"Minutes": int(s.cfg.EmailLoginTTL.Minutes()),
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	return loginToken, nil
+}
+
+func (s *emailLoginService) issuer() string {
+	return strings.TrimRight(s.cfg.IssuerURL, "/")
+}
+
+func (s *emailLoginService) audience() string {
+	return s.issuer() + EmailLoginPath
+}
+
+// emailLoginCodeHash binds the code to its login token. Only the token's
+// hash is stored, so a Redis dump is not enough to brute-force the six
+// digits offline.
+func emailLoginCodeHash(loginToken, code string) string {
+	return utils.HashToken(loginToken + ":" + code)
+}
+
+func generateEmailLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", emailLoginCodeDigits, n.Int64()), nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+const (
+	emailOutboxPollInterval   = 2 * time.Second
+	emailOutboxBatchSize      = 20
+	emailOutboxLease          = 2 * time.Minute
+	emailOutboxSendTimeout    = 30 * time.Second
+	emailOutboxMaxAttempts    = 8
+	emailOutboxInitialBackoff = 30 * time.Second
+	emailOutboxMaxBackoff     = time.Hour
+
+	// Failed messages keep their bodies for replay, but no link they carry
+	// stays valid longer than a day, so neither do they.
+	emailOutboxPurgeInterval   = 10 * time.Minute
+	emailOutboxSentRetention   = 7 * 24 * time.Hour
+	emailOutboxFailedRetention = 24 * time.Hour
+)
+
+// EmailOutboxStats is the queue depth exposed for alerting.
+type EmailOutboxStats struct {
+	Pending              int64 `json:"pending"`
+	Failed               int64 `json:"failed"`
+	OldestPendingSeconds int64 `json:"oldest_pending_seconds"`
+}
+
+type ListOutboxFilter struct {
+	Offset int
+	Limit  int
+}
+
+// EmailOutboxService delivers queued emails and lets admins inspect and
+// replay the ones that failed permanently.
+type EmailOutboxService interface {
+	Run(ctx context.Context)
+	Stats(ctx context.Context) (*EmailOutboxStats, error)
+	ListFailed(ctx context.Context, filter ListOutboxFilter) ([]models.EmailOutboxMessage, error)
+	Replay(ctx context.Context, id uuid.UUID) error
+}
+
+type emailOutboxService struct {
+	repo   repositories.EmailOutboxRepository
+	mailer mailer.Mailer
+}
+
+func NewEmailOutboxService(repo repositories.EmailOutboxRepository, mailer mailer.Mailer) EmailOutboxService {
+	return &emailOutboxService{repo: repo, mailer: mailer}
+}
+
+// Run polls for due messages until ctx is cancelled. Several instances may
+// run side by side; claimed messages are leased to one of them.
+func (s *emailOutboxService) Run(ctx context.Context) {
+	ticker := time.NewTicker(emailOutboxPollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+	for {
+		// Keep draining while batches come back full.
+		for {
+			if s.deliverBatch(ctx) < emailOutboxBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= emailOutboxPurgeInterval {
+			s.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge drops messages past their retention window.
+func (s *emailOutboxService) purge(ctx context.Context) {
+	now := time.Now().UTC()
+	if _, err := s.repo.Purge(ctx, now.Add(-emailOutboxSentRetention), now.Add(-emailOutboxFailedRetention)); err != nil && ctx.Err() == nil {
+		log.Printf("failed to purge outbox messages: %v", err)
+	}
+}
+
+// deliverBatch sends one batch of due messages and returns how many it
+// claimed.
+func (s *emailOutboxService) deliverBatch(ctx context.Context) int {
+	messages, err := s.repo.ClaimDue(ctx, time.Now().UTC(), emailOutboxBatchSize, emailOutboxLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to claim outbox messages: %v", err)
+		}
+		return 0
+	}
+
+	for i := range messages {
+		s.deliver(ctx, &messages[i])
+	}
+
+	return len(messages)
+}
+
+func (s *emailOutboxService) deliver(ctx context.Context, message *models.EmailOutboxMessage) {
+	sendCtx, cancel := context.WithTimeout(ctx, emailOutboxSendTimeout)
+	err := s.mailer.Send(sendCtx, mailer.Message{
+		To:      message.Recipients,
+		Subject: message.Subject,
+		Text:    message.TextBody,
+		HTML:    message.HTMLBody,
+	})
+	cancel()
+
+	now := time.Now().UTC()
+	attempts := message.Attempts + 1
+
+	switch {
+	case err == nil:
+		err = s.repo.MarkSent(ctx, message.ID, now)
+	case mailer.IsPermanent(err) || attempts >= emailOutboxMaxAttempts:
+		log.Printf("email %s failed permanently after %d attempts: %v", message.ID, attempts, err)
+		err = s.repo.MarkFailed(ctx, message.ID, attempts, err.Error())
+	default:
+		err = s.repo.MarkRetry(ctx, message.ID, attempts, now.Add(emailOutboxBackoff(attempts)), err.Error())
+	}
+
+	// The lease expires on its own, so a failed status update only means the
+	// message may be sent twice.
+	if err != nil {
+		log.Printf("failed to update outbox message %s: %v", message.ID, err)
+	}
+}
+
+func (s *emailOutboxService) Stats(ctx context.Context) (*EmailOutboxStats, error) {
+	stats, err := s.repo.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EmailOutboxStats{Pending: stats.Pending, Failed: stats.Failed}
+	if stats.OldestPending != nil {
+		result.OldestPendingSeconds = int64(time.Since(*stats.OldestPending).Seconds())
+	}
+
+	return result, nil
+}
+
+func (s *emailOutboxService) ListFailed(ctx context.Context, filter ListOutboxFilter) ([]models.EmailOutboxMessage, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.ListFailed(ctx, offset, limit)
+}
+
+// Replay queues a failed message for immediate delivery.
+func (s *emailOutboxService) Replay(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Requeue(ctx, id, time.Now().UTC())
+}
+
+// emailOutboxBackoff doubles the delay after every failed attempt.
+func emailOutboxBackoff(attempts int) time.Duration {
+	delay := emailOutboxInitialBackoff
+	for i := 1; i < attempts && delay < emailOutboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, emailOutboxMaxBackoff)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+// EmailService renders transactional templates in the user's locale and
+// branded for the client the user came from, then queues them in the outbox.
+// Called inside a transaction, the email is only sent if it commits.
+type EmailService interface {
+	Send(ctx context.Context, user *models.User, clientID string, name mailer.TemplateName, data map[string]any) error
+}
+
+type emailService struct {
+	templates *mailer.Templates
+	outbox    repositories.EmailOutboxRepository
+	clients   repositories.ClientRepository
+}
+
+func NewEmailService(templates *mailer.Templates, outbox repositories.EmailOutboxRepository, clients repositories.ClientRepository) EmailService {
+	return &emailService{templates: templates, outbox: outbox, clients: clients}
+}
+
+// Send fills in the recipient's Name unless data already has one. An unknown
+// clientID falls back to the default branding rather than failing the send.
+func (s *emailService) Send(ctx context.Context, user *models.User, clientID string, name mailer.TemplateName, data map[string]any) error {
+	values := make(map[string]any, len(data)+1)
+	values["Name"] = recipientName(user)
+	for key, value := range data {
+		values[key] = value
+	}
+
+	brand, err := s.branding(ctx, clientID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.templates.Render(name, user.Locale, brand, values)
+	if err != nil {
+		return err
+	}
+
+	return s.outbox.Create(ctx, &models.EmailOutboxMessage{
+		ID:            uuid.New(),
+		Template:      string(name),
+		Recipients:    []string{user.Email},
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HTMLBody:      msg.HTML,
+		Status:        models.EmailOutboxStatusPending,
+		NextAttemptAt: time.Now().UTC(),
+	})
+}
+
+func (s *emailService) branding(ctx context.Context, clientID string) (mailer.Branding, error) {
+	if clientID == "" {
+		return mailer.DefaultBranding, nil
+	}
+
+	client, err := s.clients.GetByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrClientNotFound) {
+			return mailer.DefaultBranding, nil
+		}
+		return mailer.Branding{}, err
+	}
+
+	return ClientBranding(client), nil
+}
+
+// ClientBranding is the email branding registered for client.
+func ClientBranding(client *models.Client) mailer.Branding {
+	return mailer.Branding{Name: client.Name, LogoURL: client.LogoURI}
+}
+
+func recipientName(user *models.User) string {
+	switch {
+	case user.GivenName != "":
+		return user.GivenName
+	case user.Name != "":
+		return user.Name
+	default:
+		return user.Email
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+const (
+	emailVerificationKeyPrefix = "email_verification:"
+	emailResendKeyPrefix       = "email_verification_resend:"
+
+	// EmailVerificationPath is where verification links point; it doubles as
+	// the token audience so no other JWT from this issuer is accepted.
+	EmailVerificationPath = "/verify-email"
+)
+
+var (
+	ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	ErrResendRateLimited        = errors.New("a verification email was sent recently, try again later")
+)
+
+// emailVerificationClaims is the signed payload of a verification link. The
+// jti is recorded in Redis at issue time and deleted on use.
+type emailVerificationClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+	JWTID     string `json:"jti"`
+}
+
+type EmailVerificationService interface {
+	SendVerification(ctx context.Context, user *models.User, clientID string) error
+	Verify(ctx context.Context, token string) (*models.User, error)
+	Resend(ctx context.Context, email, clientID string) error
+}
+
+type emailVerificationService struct {
+	users  repositories.UserRepository
+	keys   keys.Manager
+	cache  CacheService
+	emails EmailService
+	cfg    config.SSOConfig
+}
+
+func NewEmailVerificationService(
+	users repositories.UserRepository,
+	keys keys.Manager,
+	cache CacheService,
+	emails EmailService,
+	cfg config.SSOConfig,
+) EmailVerificationService {
+	return &emailVerificationService{users: users, keys: keys, cache: cache, emails: emails, cfg: cfg}
+}
+
+// SendVerification mails user a single-use verification link valid for
+// EmailVerificationTTL, branded for clientID when given.
+func (s *emailVerificationService) SendVerification(ctx context.Context, user *models.User, clientID string) error {
+	now := time.Now().UTC()
+	jti := uuid.NewString()
+
+	token, err := s.keys.Sign(ctx, emailVerificationClaims{
+		Issuer:    s.issuer(),
+		Subject:   user.ID.String(),
+		Audience:  s.audience(),
+		Email:     user.Email,
+		ExpiresAt: now.Add(s.cfg.EmailVerificationTTL).Unix(),
+		IssuedAt:  now.Unix(),
+		JWTID:     jti,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.cache.Set(ctx, emailVerificationKeyPrefix+jti, []byte(user.ID.String()), s.cfg.EmailVerificationTTL); err != nil {
+		return err
+	}
+
+	link := s.audience() + "?" + url.Values{"token": {token}}.Encode()
+
+	return s.emails.Send(ctx, user, clientID, mailer.TemplateVerifyEmail, map[string]any{"Link": link})
+}
+
+// Verify redeems a verification token, marking the email verified and
+// activating a pending account. Each token works once and only for the
+// address it was issued to.
+func (s *emailVerificationService) Verify(ctx context.Context, token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	var claims emailVerificationClaims
+	if err := s.keys.Verify(ctx, token, &claims); err != nil {
+		if errors.Is(err, keys.ErrInvalidToken) || errors.Is(err, keys.ErrUnknownKey) {
+			return nil, ErrVerificationTokenInvalid
+		}
+		return nil, err
+	}
+
+	if claims.Issuer != s.issuer() ||
+		claims.Audience != s.audience() ||
+		claims.JWTID == "" ||
+		time.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	_, ok, err := s.cache.GetAndDelete(ctx, emailVerificationKeyPrefix+claims.JWTID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrVerificationTokenInvalid
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return nil, ErrVerificationTokenInvalid
+	}
+
+	if err := s.users.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	user.EmailVerified = true
+	if user.Status == models.UserStatusPending {
+		user.Status = models.UserStatusActive
+	}
+
+	return user, nil
+}
+
+// Resend mails a fresh link to an unverified account. The rate limit is keyed
+// on the address alone and the outcome is the same whether or not an account
+// exists, so callers cannot probe for registered emails.
+func (s *emailVerificationService) Resend(ctx context.Context, email, clientID string) error {
+	email = strings.TrimSpace(email)
+
+	allowed, err := s.cache.SetIfAbsent(ctx, emailResendKey(email), []byte("1"), s.cfg.EmailResendInterval)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrResendRateLimited
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified || user.Status == models.UserStatusDisabled {
+		return nil
+	}
+
+	return s.SendVerification(ctx, user, clientID)
+}
+
+func (s *emailVerificationService) issuer() string {
+	return strings.TrimRight(s.cfg.IssuerURL, "/")
+}
+
+func (s *emailVerificationService) audience() string {
+	return s.issuer() + EmailVerificationPath
+}
+
+func emailResendKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return emailResendKeyPrefix + hex.EncodeToString(sum[:])
+}
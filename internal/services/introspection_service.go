@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+
+	introspectionCacheTTL       = 5 * time.Minute
+	introspectionCacheKeyPrefix = "introspection:"
+)
+
+// IntrospectionResponse is the RFC 7662 section 2.2 response. Inactive tokens
+// only ever carry active=false.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	JWTID     string   `json:"jti,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+}
+
+type IntrospectionService interface {
+	Introspect(ctx context.Context, caller *models.Client, token, tokenTypeHint string) (*IntrospectionResponse, error)
+}
+
+type introspectionService struct {
+	tokens        TokenService
+	refreshTokens RefreshTokenService
+	cache         CacheService
+}
+
+func NewIntrospectionService(tokens TokenService, refreshTokens RefreshTokenService, cache CacheService) IntrospectionService {
+	return &introspectionService{tokens: tokens, refreshTokens: refreshTokens, cache: cache}
+}
+
+// Introspect reports on access and refresh tokens. The hint only decides
+// which kind is tried first, as RFC 7662 requires.
+func (s *introspectionService) Introspect(ctx context.Context, caller *models.Client, token, tokenTypeHint string) (*IntrospectionResponse, error) {
+	if !isAuthenticatedConfidential(caller) {
+		return nil, newOAuthError(OAuthErrorInvalidClient, "introspection requires an authenticated confidential client")
+	}
+	if token == "" {
+		return nil, newOAuthError(OAuthErrorInvalidRequest, "token is required")
+	}
+
+	lookups := []func(context.Context, *models.Client, string) (*IntrospectionResponse, error){
+		s.introspectAccessToken,
+		s.introspectRefreshToken,
+	}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		response, err := lookup(ctx, caller, token)
+		if err != nil {
+			return nil, err
+		}
+		if response.Active {
+			return response, nil
+		}
+	}
+
+	return &IntrospectionResponse{Active: false}, nil
+}
+
+func (s *introspectionService) introspectAccessToken(ctx context.Context, _ *models.Client, token string) (*IntrospectionResponse, error) {
+	key := introspectionCacheKey(token)
+
+	var cached IntrospectionResponse
+	ok, err := s.cache.GetJSON(ctx, key, &cached)
+	if err != nil {
+		log.Printf("introspection cache read failed: %v", err)
+	} else if ok && time.Now().UTC().Before(time.Unix(cached.ExpiresAt, 0)) {
+		return &cached, nil
+	}
+
+	claims, err := s.tokens.ValidateAccessToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAccessToken) {
+			return &IntrospectionResponse{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	response := &IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Subject:   claims.Subject,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		JWTID:     claims.JWTID,
+		TokenType: TokenTypeHintAccessToken,
+	}
+
+	ttl := min(introspectionCacheTTL, time.Until(time.Unix(claims.ExpiresAt, 0)))
+	if ttl > 0 {
+		if err := s.cache.SetJSON(ctx, key, response, ttl); err != nil {
+			log.Printf("introspection cache write failed: %v", err)
+		}
+	}
+
+	return response, nil
+}
+
+// introspectRefreshToken only answers the client the refresh token was
+// issued to; for everyone else it is simply inactive.
+func (s *introspectionService) introspectRefreshToken(ctx context.Context, caller *models.Client, token string) (*IntrospectionResponse, error) {
+	record, err := s.refreshTokens.Lookup(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenInvalid) {
+			return &IntrospectionResponse{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	if record.ClientID != caller.ClientID {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(record.Scopes, " "),
+		ClientID:  record.ClientID,
+		Subject:   record.UserID.String(),
+		ExpiresAt: record.ExpiresAt.Unix(),
+		IssuedAt:  record.CreatedAt.Unix(),
+		TokenType: TokenTypeHintRefreshToken,
+	}, nil
+}
+
+func introspectionCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return introspectionCacheKeyPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	loginFailuresAccountKeyPrefix = "login_failures:account:"
+	loginFailuresIPKeyPrefix      = "login_failures:ip:"
+	loginBackoffAccountKeyPrefix  = "login_backoff:account:"
+	loginBackoffIPKeyPrefix       = "login_backoff:ip:"
+	loginLockKeyPrefix            = "login_lock:"
+)
+
+var ErrLoginThrottled = errors.New("too many failed sign-in attempts, try again later")
+
+// LoginThrottledError is returned while an account or source IP must wait
+// before its next password attempt. It matches ErrLoginThrottled.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
+
+// LoginThrottleService slows down password guessing. Accounts are tracked by
+// the submitted email rather than by user, so unknown emails are throttled
+// and locked exactly like real accounts and responses reveal neither.
+type LoginThrottleService interface {
+	// Check returns a *LoginThrottledError while email or ip must wait.
+	Check(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context, email, ip string) error
+	RecordSuccess(ctx context.Context, email string) error
+	// Unlock lifts a lockout and clears the failure count of a user.
+	Unlock(ctx context.Context, userID uuid.UUID) error
+}
+
+type loginThrottleService struct {
+	users  repositories.UserRepository
+	cache  CacheService
+	emails EmailService
+	audit  AuditService
+	cfg    config.LockoutConfig
+}
+
+func NewLoginThrottleService(
+	users repositories.UserRepository,
+	cache CacheService,
+	emails EmailService,
+	audit AuditService,
+	cfg config.SSOConfig,
+) LoginThrottleService {
+	return &loginThrottleService{users: users, cache: cache, emails: emails, audit: audit, cfg: cfg.Lockout}
+}
+
+func (s *loginThrottleService) Check(ctx context.Context, email, ip string) error {
+	account := loginThrottleKey(email)
+	now := time.Now()
+
+	var until time.Time
+	for _, key := range []string{
+		loginLockKeyPrefix + account,
+		loginBackoffAccountKeyPrefix + account,
+		loginBackoffIPKeyPrefix + loginThrottleKey(ip),
+	} {
+		deadline, err := s.deadline(ctx, key)
+		if err != nil {
+			return err
+		}
+		if deadline.After(until) {
+			until = deadline
+		}
+	}
+
+	if until.After(now) {
+		return &LoginThrottledError{RetryAfter: until.Sub(now)}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt against email and ip, delaying the
+// next attempt once the free attempts are used up. Reaching the lockout
+// threshold soft-locks the account until the lockout duration elapses.
+func (s *loginThrottleService) RecordFailure(ctx context.Context, email, ip string) error {
+	account := loginThrottleKey(email)
+	now := time.Now()
+
+	failures, err := s.cache.Increment(ctx, loginFailuresAccountKeyPrefix+account, s.cfg.FailureWindow)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.Threshold > 0 && failures >= int64(s.cfg.Threshold) {
+		if err := s.lock(ctx, email, ip, now.Add(s.cfg.Duration)); err != nil {
+			return err
+		}
+	} else if err := s.backoff(ctx, loginBackoffAccountKeyPrefix+account, failures, s.cfg.FreeAttempts, now); err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	ipKey := loginThrottleKey(ip)
+	ipFailures, err := s.cache.Increment(ctx, loginFailuresIPKeyPrefix+ipKey, s.cfg.FailureWindow)
+	if err != nil {
+		return err
+	}
+
+	return s.backoff(ctx, loginBackoffIPKeyPrefix+ipKey, ipFailures, s.cfg.IPFreeAttempts, now)
+}
+
+// RecordSuccess forgets the account's failures. The source IP keeps its
+// count so one valid account cannot launder guesses against others.
+func (s *loginThrottleService) RecordSuccess(ctx context.Context, email string) error {
+	account := loginThrottleKey(email)
+
+	if err := s.cache.Delete(ctx, loginFailuresAccountKeyPrefix+account); err != nil {
+		return err
+	}
+	return s.cache.Delete(ctx, loginBackoffAccountKeyPrefix+account)
+}
+
+func (s *loginThrottleService) Unlock(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	account := loginThrottleKey(user.Email)
+	for _, key := range []string{
+		loginLockKeyPrefix + account,
+		loginFailuresAccountKeyPrefix + account,
+		loginBackoffAccountKeyPrefix + account,
+	} {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return s.audit.Record(ctx, RecordAuditEventParams{
+		Type:   models.AuditEventAccountUnlocked,
+		UserID: &userID,
+	})
+}
+
+// lock starts a lockout unless one is already running and notifies the
+// account owner. The failure count restarts so the account gets its free
+// attempts back once the lock expires.
+func (s *loginThrottleService) lock(ctx context.Context, email, ip string, until time.Time) error {
+	account := loginThrottleKey(email)
+
+	locked, err := s.cache.SetIfAbsent(ctx, loginLockKeyPrefix+account, []byte(until.UTC().Format(time.RFC3339Nano)), s.cfg.Duration)
+	if err != nil {
+		return err
+	}
+	if err := s.cache.Delete(ctx, loginFailuresAccountKeyPrefix+account); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	// Looking the account up and queueing the email happen off the request
+	// path; otherwise the locking response would be slower for real accounts.
+	go s.notifyLocked(context.WithoutCancel(ctx), email, ip, until)
+
+	return nil
+}
+
+func (s *loginThrottleService) notifyLocked(ctx context.Context, email, ip string, until time.Time) {
+	user, err := s.users.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if !errors.Is(err, repositories.ErrUserNotFound) {
+			log.Printf("failed to load locked account: %v", err)
+		}
+		return
+	}
+
+	if err := s.audit.Record(ctx, RecordAuditEventParams{
+		Type:     models.AuditEventAccountLocked,
+		UserID:   &user.ID,
+		Metadata: map[string]any{"ip_address": ip, "until": until.UTC()},
+	}); err != nil {
+		log.Printf("failed to audit lockout of user %s: %v", user.ID, err)
+	}
+
+	if err := s.emails.Send(ctx, user, "", mailer.TemplateSecurityAlert, map[string]any{
+		"Event":     "account_locked",
+		"IPAddress": ip,
+		"Until":     until.UTC().Format("2006-01-02 15:04 UTC"),
+	}); err != nil {
+		log.Printf("failed to queue lockout email for user %s: %v", user.ID, err)
+	}
+}
+
+// backoff delays the next attempt by BackoffBase doubled for every failure
+// past the free ones, capped at BackoffMax.
+func (s *loginThrottleService) backoff(ctx context.Context, key string, failures int64, free int, now time.Time) error {
+	excess := failures - int64(free)
+	if excess <= 0 {
+		return nil
+	}
+
+	delay := s.cfg.BackoffBase
+	for i := int64(1); i < excess && delay < s.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.cfg.BackoffMax)
+	if delay <= 0 {
+		return nil
+	}
+
+	return s.cache.Set(ctx, key, []byte(now.Add(delay).UTC().Format(time.RFC3339Nano)), delay)
+}
+
+// deadline reads the time stored under key; the zero time when absent.
+func (s *loginThrottleService) deadline(ctx context.Context, key string) (time.Time, error) {
+	value, ok, err := s.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return time.Time{}, err
+	}
+
+	until, err := time.Parse(time.RFC3339Nano, string(value))
+	if err != nil {
+		return time.Time{}, nil
+	}
+
+	return until, nil
+}
+
+func loginThrottleKey(value string) string {
+	return utils.HashToken(strings.ToLower(strings.TrimSpace(value)))
+}
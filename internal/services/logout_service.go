@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	logoutConfirmationKeyPrefix    = "logout_confirmation:"
+	logoutConfirmationTTL          = 10 * time.Minute
+	logoutConfirmationEntropyBytes = 32
+)
+
+var (
+	ErrInvalidIDTokenHint            = errors.New("id_token_hint is invalid")
+	ErrEndSessionClientMismatch      = errors.New("client_id does not match id_token_hint")
+	ErrEndSessionUnknownClient       = errors.New("unknown client_id")
+	ErrPostLogoutRedirectURIMismatch = errors.New("post_logout_redirect_uri is not registered for the client")
+	ErrPostLogoutRedirectUnbound     = errors.New("post_logout_redirect_uri requires id_token_hint or client_id")
+	ErrLogoutConfirmationInvalid     = errors.New("logout confirmation is invalid or expired")
+)
+
+type EndSessionParams struct {
+	IDTokenHint           string
+	ClientID              string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// EndSessionRequest is a validated RP-initiated logout request. Client,
+// Subject and SessionID are only known when the relying party identified
+// itself.
+type EndSessionRequest struct {
+	Client                *models.Client
+	Subject               string
+	SessionID             string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// SessionTracker is the part of the session store logout needs: which
+// clients took part in a session, and which sessions a user holds.
+type SessionTracker interface {
+	AddClient(ctx context.Context, sessionID, clientID string) error
+	Terminate(ctx context.Context, sessionID string) ([]string, error)
+	SessionIDsByUser(ctx context.Context, userID uuid.UUID) ([]string, error)
+}
+
+type LogoutService interface {
+	ValidateEndSession(ctx context.Context, params EndSessionParams) (*EndSessionRequest, error)
+	// StartLogoutConfirmation issues a one-time token the user must send
+	// back to confirm ending sessionID; ConfirmLogout redeems it.
+	StartLogoutConfirmation(ctx context.Context, sessionID string) (string, error)
+	ConfirmLogout(ctx context.Context, sessionID, token string) error
+	TrackParticipant(ctx context.Context, sessionID, clientID string) error
+	TerminateSession(ctx context.Context, sessionID, subject string) ([]string, error)
+	RevokeSession(ctx context.Context, sessionID, subject string) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type logoutService struct {
+	clients       repositories.ClientRepository
+	keys          keys.Manager
+	sessions      SessionTracker
+	refreshTokens RefreshTokenService
+	backchannel   BackchannelLogoutDispatcher
+	cache         CacheService
+	cfg           config.SSOConfig
+}
+
+func NewLogoutService(
+	clients repositories.ClientRepository,
+	keys keys.Manager,
+	sessions SessionTracker,
+	refreshTokens RefreshTokenService,
+	backchannel BackchannelLogoutDispatcher,
+	cache CacheService,
+	cfg config.SSOConfig,
+) LogoutService {
+	return &logoutService{
+		clients:       clients,
+		keys:          keys,
+		sessions:      sessions,
+		refreshTokens: refreshTokens,
+		backchannel:   backchannel,
+		cache:         cache,
+		cfg:           cfg,
+	}
+}
+
+// ValidateEndSession applies OIDC RP-Initiated Logout 1.0 section 2. An
+// expired id_token_hint is still accepted: it only identifies the session.
+func (s *logoutService) ValidateEndSession(ctx context.Context, params EndSessionParams) (*EndSessionRequest, error) {
+	req := &EndSessionRequest{State: params.State}
+	clientID := params.ClientID
+
+	if params.IDTokenHint != "" {
+		var claims IDTokenClaims
+		if err := s.keys.Verify(ctx, params.IDTokenHint, &claims); err != nil {
+			if errors.Is(err, keys.ErrInvalidToken) || errors.Is(err, keys.ErrUnknownKey) {
+				return nil, ErrInvalidIDTokenHint
+			}
+			return nil, err
+		}
+		if claims.Issuer != strings.TrimRight(s.cfg.IssuerURL, "/") {
+			return nil, ErrInvalidIDTokenHint
+		}
+		if clientID != "" && clientID != claims.Audience {
+			return nil, ErrEndSessionClientMismatch
+		}
+
+		clientID = claims.Audience
+		req.Subject = claims.Subject
+		req.SessionID = claims.SessionID
+	}
+
+	if clientID != "" {
+		client, err := s.clients.GetByClientID(ctx, clientID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrClientNotFound) {
+				return nil, ErrEndSessionUnknownClient
+			}
+			return nil, err
+		}
+		req.Client = client
+	}
+
+	if params.PostLogoutRedirectURI != "" {
+		if req.Client == nil {
+			return nil, ErrPostLogoutRedirectUnbound
+		}
+		if !containsString(req.Client.PostLogoutRedirectURIs, params.PostLogoutRedirectURI) {
+			return nil, ErrPostLogoutRedirectURIMismatch
+		}
+		req.PostLogoutRedirectURI = params.PostLogoutRedirectURI
+	}
+
+	return req, nil
+}
+
+func (s *logoutService) StartLogoutConfirmation(ctx context.Context, sessionID string) (string, error) {
+	token, err := utils.GenerateRandomToken(logoutConfirmationEntropyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.cache.Set(ctx, logoutConfirmationKeyPrefix+utils.HashToken(token), []byte(sessionID), logoutConfirmationTTL); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *logoutService) ConfirmLogout(ctx context.Context, sessionID, token string) error {
+	if token == "" {
+		return ErrLogoutConfirmationInvalid
+	}
+
+	stored, ok, err := s.cache.GetAndDelete(ctx, logoutConfirmationKeyPrefix+utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if !ok || string(stored) != sessionID {
+		return ErrLogoutConfirmationInvalid
+	}
+
+	return nil
+}
+
+// TrackParticipant remembers that clientID received tokens in sessionID so it
+// can be notified when the session ends.
+func (s *logoutService) TrackParticipant(ctx context.Context, sessionID, clientID string) error {
+	return s.sessions.AddClient(ctx, sessionID, clientID)
+}
+
+// TerminateSession notifies every client that took part in sessionID.
+// Back-channel deliveries happen in the background; the returned front-channel
+// logout URLs must be loaded by the user agent.
+func (s *logoutService) TerminateSession(ctx context.Context, sessionID, subject string) ([]string, error) {
+	if sessionID == "" {
+		return nil, nil
+	}
+
+	participants, err := s.sessions.Terminate(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var frontchannelURLs []string
+
+	for _, clientID := range participants {
+		client, err := s.clients.GetByClientID(ctx, clientID)
+		if err != nil {
+			log.Printf("skipping back-channel logout for client %s: %v", clientID, err)
+			continue
+		}
+
+		if err := s.backchannel.Enqueue(ctx, client, subject, sessionID); err != nil {
+			log.Printf("failed to enqueue back-channel logout for client %s: %v", clientID, err)
+		}
+
+		if logoutURL, ok := s.frontchannelLogoutURL(client, sessionID); ok {
+			frontchannelURLs = append(frontchannelURLs, logoutURL)
+		}
+	}
+
+	return frontchannelURLs, nil
+}
+
+// RevokeSession ends a session on the user's or an admin's behalf. Unlike a
+// browser logout it also revokes the refresh tokens issued within it, and
+// only back-channel clients can be told.
+func (s *logoutService) RevokeSession(ctx context.Context, sessionID, subject string) error {
+	if _, err := s.TerminateSession(ctx, sessionID, subject); err != nil {
+		return err
+	}
+	return s.refreshTokens.RevokeSession(ctx, sessionID)
+}
+
+// RevokeUserSessions ends every session the user holds and revokes all of
+// their refresh tokens, including ones not bound to a session.
+func (s *logoutService) RevokeUserSessions(ctx context.Context, userID uuid.UUID) error {
+	sessionIDs, err := s.sessions.SessionIDsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if _, err := s.TerminateSession(ctx, sessionID, userID.String()); err != nil {
+			return err
+		}
+	}
+
+	return s.refreshTokens.RevokeUser(ctx, userID)
+}
+
+// frontchannelLogoutURL builds the URL rendered in the logout page iframe,
+// adding iss and sid when the client asked for them (OIDC Front-Channel
+// Logout 1.0 section 2).
+func (s *logoutService) frontchannelLogoutURL(client *models.Client, sessionID string) (string, bool) {
+	if client.FrontchannelLogoutURI == "" {
+		return "", false
+	}
+
+	target, err := url.Parse(client.FrontchannelLogoutURI)
+	if err != nil {
+		log.Printf("invalid front-channel logout uri for client %s: %v", client.ClientID, err)
+		return "", false
+	}
+
+	if client.FrontchannelLogoutSessionRequired {
+		query := target.Query()
+		query.Set("iss", strings.TrimRight(s.cfg.IssuerURL, "/"))
+		query.Set("sid", sessionID)
+		target.RawQuery = query.Encode()
+	}
+
+	return target.String(), true
+}
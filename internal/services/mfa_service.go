@@ -0,0 +1,698 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+	"github.com/mohammadhprp/passport/internal/webauthn"
+)
+
+const (
+	MFAMethodTOTP       = "totp"
+	MFAMethodWebAuthn   = "webauthn"
+	MFAMethodBackupCode = "backup_code"
+
+	// mfaChangeBackupCodes is the method reported in mfa_changed emails when
+	// backup codes are regenerated.
+	mfaChangeBackupCodes = "backup_codes"
+	// mfaChangeAll is reported when a reset removes every factor.
+	mfaChangeAll = "all"
+
+	backupCodeCount = 10
+	backupCodeBytes = 10
+
+	mfaChallengeKeyPrefix   = "mfa_challenge:"
+	mfaAttemptsKeyPrefix    = "mfa_challenge_attempts:"
+	mfaChallengeEntropy     = 32
+	mfaChallengeMaxAttempts = 5
+)
+
+var (
+	ErrMFANotConfigured     = errors.New("mfa encryption key is not configured")
+	ErrMFAFactorNotFound    = errors.New("mfa factor not found")
+	ErrMFAFactorConfirmed   = errors.New("mfa factor is already confirmed")
+	ErrInvalidMFACode       = errors.New("invalid mfa code")
+	ErrMFAChallengeInvalid  = errors.New("mfa challenge is invalid or expired")
+	ErrUnsupportedMFAMethod = errors.New("unsupported mfa method")
+	ErrMFANotEnrolled       = errors.New("no confirmed mfa factor")
+)
+
+// TOTPEnrollment is returned once when a TOTP factor is created. The secret
+// is never shown again.
+type TOTPEnrollment struct {
+	FactorID        uuid.UUID
+	Secret          string
+	ProvisioningURI string
+}
+
+// MFAProof is the second factor presented for a login challenge: a code
+// for totp and backup_code, an assertion for webauthn.
+type MFAProof struct {
+	Method    string
+	Code      string
+	Assertion *webauthn.AssertionResponse
+}
+
+// StartChallengeParams describe a login waiting for its second factor. AMR
+// lists the methods already used. SessionID is set when an existing session
+// is being stepped up; the challenge is then only good for that session.
+type StartChallengeParams struct {
+	UserID     uuid.UUID
+	LoginState string
+	AMR        []string
+	SessionID  string
+}
+
+// MFAChallengeResult identifies the user behind a solved login challenge.
+type MFAChallengeResult struct {
+	UserID     uuid.UUID
+	LoginState string
+	AMR        []string
+	SessionID  string
+}
+
+type mfaChallenge struct {
+	UserID     uuid.UUID `json:"user_id"`
+	LoginState string    `json:"login_state,omitempty"`
+	AMR        []string  `json:"amr"`
+	SessionID  string    `json:"sid,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type MFAService interface {
+	ListFactors(ctx context.Context, userID uuid.UUID) ([]models.MFAFactor, error)
+	Methods(ctx context.Context, userID uuid.UUID) ([]string, error)
+	HasMFA(ctx context.Context, userID uuid.UUID) (bool, error)
+	BackupCodesRemaining(ctx context.Context, userID uuid.UUID) (int64, error)
+	EnrollTOTP(ctx context.Context, userID uuid.UUID, label string) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, factorID uuid.UUID, code string) ([]string, error)
+	RemoveFactor(ctx context.Context, userID, factorID uuid.UUID) error
+	RegisterWebAuthn(ctx context.Context, userID uuid.UUID, label string, response *webauthn.RegistrationResponse) (*models.WebAuthnCredential, []string, error)
+	RemoveWebAuthnCredential(ctx context.Context, userID, credentialID uuid.UUID) error
+	RegenerateBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error)
+	Reset(ctx context.Context, userID uuid.UUID) error
+	StartChallenge(ctx context.Context, params StartChallengeParams) (string, error)
+	WebAuthnChallengeOptions(ctx context.Context, token string) (*webauthn.RequestOptions, error)
+	CompleteChallenge(ctx context.Context, token string, proof MFAProof) (*MFAChallengeResult, error)
+}
+
+type mfaService struct {
+	repo     repositories.MFARepository
+	users    repositories.UserRepository
+	webAuthn WebAuthnService
+	cache    CacheService
+	emails   EmailService
+	audit    AuditService
+	cfg      config.SSOConfig
+}
+
+func NewMFAService(
+	repo repositories.MFARepository,
+	users repositories.UserRepository,
+	webAuthn WebAuthnService,
+	cache CacheService,
+	emails EmailService,
+	audit AuditService,
+	cfg config.SSOConfig,
+) MFAService {
+	return &mfaService{repo: repo, users: users, webAuthn: webAuthn, cache: cache, emails: emails, audit: audit, cfg: cfg}
+}
+
+func (s *mfaService) ListFactors(ctx context.Context, userID uuid.UUID) ([]models.MFAFactor, error) {
+	return s.repo.ListFactors(ctx, userID)
+}
+
+// Methods lists the second factors the user can currently answer a login
+// challenge with. Backup codes only count alongside a real factor.
+func (s *mfaService) Methods(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	factors, err := s.confirmedFactors(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := s.webAuthn.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []string
+	if len(factors) > 0 {
+		methods = append(methods, MFAMethodTOTP)
+	}
+	if len(credentials) > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	remaining, err := s.repo.CountUnusedBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		methods = append(methods, MFAMethodBackupCode)
+	}
+
+	return methods, nil
+}
+
+// HasMFA reports whether the user has at least one confirmed factor and must
+// therefore pass a challenge after their password.
+func (s *mfaService) HasMFA(ctx context.Context, userID uuid.UUID) (bool, error) {
+	methods, err := s.Methods(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(methods) > 0, nil
+}
+
+func (s *mfaService) BackupCodesRemaining(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.repo.CountUnusedBackupCodes(ctx, userID)
+}
+
+// EnrollTOTP creates an unconfirmed TOTP factor. It does not protect the
+// account until ConfirmTOTP succeeds.
+func (s *mfaService) EnrollTOTP(ctx context.Context, userID uuid.UUID, label string) (*TOTPEnrollment, error) {
+	key, err := s.encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := utils.EncryptValue(key, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = "Authenticator app"
+	}
+
+	factor := &models.MFAFactor{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Type:            models.MFAFactorTOTP,
+		Label:           label,
+		SecretEncrypted: encrypted,
+	}
+	if err := s.repo.CreateFactor(ctx, factor); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		FactorID:        factor.ID,
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP activates a pending factor once the user proves possession
+// with a valid code. The first confirmed factor also issues backup codes,
+// which are returned in plain text exactly once.
+func (s *mfaService) ConfirmTOTP(ctx context.Context, userID, factorID uuid.UUID, code string) ([]string, error) {
+	factor, err := s.getFactor(ctx, userID, factorID)
+	if err != nil {
+		return nil, err
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrMFAFactorConfirmed
+	}
+
+	step, ok, err := s.validateTOTP(factor, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	if err := s.repo.ConfirmFactor(ctx, factor.ID, step, time.Now().UTC()); err != nil {
+		if errors.Is(err, repositories.ErrMFAFactorNotFound) {
+			return nil, ErrMFAFactorConfirmed
+		}
+		return nil, err
+	}
+
+	codes, err := s.ensureBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.notify(ctx, userID, models.AuditEventMFAEnrolled, "enrolled", string(factor.Type)); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// RemoveFactor deletes a factor. Removing the last confirmed factor also
+// drops the backup codes, which would otherwise keep MFA half-enabled.
+func (s *mfaService) RemoveFactor(ctx context.Context, userID, factorID uuid.UUID) error {
+	factor, err := s.getFactor(ctx, userID, factorID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteFactor(ctx, userID, factorID); err != nil {
+		if errors.Is(err, repositories.ErrMFAFactorNotFound) {
+			return ErrMFAFactorNotFound
+		}
+		return err
+	}
+
+	if err := s.dropOrphanedBackupCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	if factor.ConfirmedAt == nil {
+		return nil
+	}
+
+	return s.notify(ctx, userID, models.AuditEventMFARemoved, "removed", string(factor.Type))
+}
+
+// RegisterWebAuthn completes a security key or passkey registration. Like
+// the first TOTP factor, the first credential also issues backup codes.
+func (s *mfaService) RegisterWebAuthn(ctx context.Context, userID uuid.UUID, label string, response *webauthn.RegistrationResponse) (*models.WebAuthnCredential, []string, error) {
+	credential, err := s.webAuthn.FinishRegistration(ctx, userID, label, response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codes, err := s.ensureBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.notify(ctx, userID, models.AuditEventMFAEnrolled, "enrolled", MFAMethodWebAuthn); err != nil {
+		return nil, nil, err
+	}
+
+	return credential, codes, nil
+}
+
+func (s *mfaService) RemoveWebAuthnCredential(ctx context.Context, userID, credentialID uuid.UUID) error {
+	if err := s.webAuthn.DeleteCredential(ctx, userID, credentialID); err != nil {
+		return err
+	}
+
+	if err := s.dropOrphanedBackupCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.notify(ctx, userID, models.AuditEventMFARemoved, "removed", MFAMethodWebAuthn)
+}
+
+// RegenerateBackupCodes invalidates all previous backup codes and returns a
+// fresh set.
+func (s *mfaService) RegenerateBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	enabled, err := s.HasMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrMFANotEnrolled
+	}
+
+	codes, err := s.replaceBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.notify(ctx, userID, models.AuditEventMFAEnrolled, "enrolled", mfaChangeBackupCodes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Reset removes every factor and backup code of the user. It is the
+// administrative recovery path for users who lost their authenticator.
+func (s *mfaService) Reset(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.users.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteFactorsByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.webAuthn.DeleteUserCredentials(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteBackupCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.notify(ctx, userID, models.AuditEventMFAReset, "reset", mfaChangeAll)
+}
+
+// StartChallenge parks a login that passed its first factor until the second
+// factor is presented and returns the opaque token identifying it.
+func (s *mfaService) StartChallenge(ctx context.Context, params StartChallengeParams) (string, error) {
+	token, err := utils.GenerateRandomToken(mfaChallengeEntropy)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := mfaChallenge{
+		UserID:     params.UserID,
+		LoginState: params.LoginState,
+		AMR:        params.AMR,
+		SessionID:  params.SessionID,
+		ExpiresAt:  time.Now().Add(s.cfg.MFAChallengeTTL),
+	}
+	if err := s.cache.SetJSON(ctx, mfaChallengeKeyPrefix+utils.HashToken(token), challenge, s.cfg.MFAChallengeTTL); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// WebAuthnChallengeOptions starts a WebAuthn assertion for the user of a
+// pending login challenge.
+func (s *mfaService) WebAuthnChallengeOptions(ctx context.Context, token string) (*webauthn.RequestOptions, error) {
+	challenge, _, err := s.loadChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webAuthn.BeginLogin(ctx, &challenge.UserID)
+}
+
+// CompleteChallenge verifies the second factor for a pending login. The
+// challenge is consumed on success and after too many wrong codes.
+func (s *mfaService) CompleteChallenge(ctx context.Context, token string, proof MFAProof) (*MFAChallengeResult, error) {
+	switch proof.Method {
+	case MFAMethodTOTP, MFAMethodBackupCode:
+	case MFAMethodWebAuthn:
+		if proof.Assertion == nil {
+			return nil, ErrInvalidMFACode
+		}
+	default:
+		return nil, ErrUnsupportedMFAMethod
+	}
+
+	challenge, key, err := s.loadChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attempts are counted atomically before any code is checked, so parallel
+	// guesses cannot share one read of the counter.
+	attemptsKey := mfaAttemptsKeyPrefix + utils.HashToken(token)
+	attempts, err := s.cache.Increment(ctx, attemptsKey, s.cfg.MFAChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	amr := slices.Clone(challenge.AMR)
+	var ok bool
+	switch proof.Method {
+	case MFAMethodTOTP:
+		ok, err = s.verifyTOTP(ctx, challenge.UserID, proof.Code)
+		amr = appendAMR(amr, AMROTP)
+	case MFAMethodWebAuthn:
+		var login *WebAuthnLogin
+		login, err = s.webAuthn.FinishLogin(ctx, &challenge.UserID, proof.Assertion)
+		if isWebAuthnRejection(err) {
+			err = nil
+		}
+		if login != nil {
+			ok = true
+			amr = appendAMR(amr, login.AMR)
+		}
+	case MFAMethodBackupCode:
+		ok, err = s.useBackupCode(ctx, challenge.UserID, proof.Code)
+	}
+	amr = appendAMR(amr, AMRMFA)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	// GetAndDelete makes the challenge single use even if two requests
+	// present valid codes at the same time.
+	_, consumed, err := s.cache.GetAndDelete(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if err := s.cache.Delete(ctx, attemptsKey); err != nil {
+		return nil, err
+	}
+
+	return &MFAChallengeResult{
+		UserID:     challenge.UserID,
+		LoginState: challenge.LoginState,
+		AMR:        amr,
+		SessionID:  challenge.SessionID,
+	}, nil
+}
+
+func (s *mfaService) loadChallenge(ctx context.Context, token string) (*mfaChallenge, string, error) {
+	if token == "" {
+		return nil, "", ErrMFAChallengeInvalid
+	}
+
+	key := mfaChallengeKeyPrefix + utils.HashToken(token)
+
+	var challenge mfaChallenge
+	found, err := s.cache.GetJSON(ctx, key, &challenge)
+	if err != nil {
+		return nil, "", err
+	}
+	if !found || time.Now().After(challenge.ExpiresAt) {
+		return nil, "", ErrMFAChallengeInvalid
+	}
+
+	return &challenge, key, nil
+}
+
+// verifyTOTP accepts code if any confirmed TOTP factor produces it for a
+// step after that factor's last accepted one.
+func (s *mfaService) verifyTOTP(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	factors, err := s.confirmedFactors(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range factors {
+		factor := &factors[i]
+		if factor.Type != models.MFAFactorTOTP {
+			continue
+		}
+
+		step, ok, err := s.validateTOTP(factor, code)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := s.repo.MarkFactorUsed(ctx, factor.ID, step, time.Now().UTC()); err != nil {
+			if errors.Is(err, repositories.ErrMFACodeAlreadyUsed) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *mfaService) useBackupCode(ctx context.Context, userID uuid.UUID, code string) (bool, error) {
+	normalized := normalizeBackupCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+
+	err := s.repo.UseBackupCode(ctx, userID, utils.HashToken(normalized), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, repositories.ErrBackupCodeNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *mfaService) validateTOTP(factor *models.MFAFactor, code string) (int64, bool, error) {
+	key, err := s.encryptionKey()
+	if err != nil {
+		return 0, false, err
+	}
+
+	secret, err := utils.DecryptValue(key, factor.SecretEncrypted)
+	if err != nil {
+		return 0, false, err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), factor.LastUsedStep)
+	return step, ok, nil
+}
+
+// ensureBackupCodes issues backup codes when the user has none left, which
+// is the case when their first factor is enrolled.
+func (s *mfaService) ensureBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	remaining, err := s.repo.CountUnusedBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, nil
+	}
+	return s.replaceBackupCodes(ctx, userID)
+}
+
+// dropOrphanedBackupCodes deletes the backup codes once no factor remains,
+// so they cannot keep MFA half-enabled.
+func (s *mfaService) dropOrphanedBackupCodes(ctx context.Context, userID uuid.UUID) error {
+	enabled, err := s.HasMFA(ctx, userID)
+	if err != nil || enabled {
+		return err
+	}
+	return s.repo.DeleteBackupCodes(ctx, userID)
+}
+
+func (s *mfaService) replaceBackupCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, backupCodeCount)
+	hashes := make([]string, 0, backupCodeCount)
+
+	for range backupCodeCount {
+		code, err := generateBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeBackupCode(code)))
+	}
+
+	if err := s.repo.ReplaceBackupCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *mfaService) confirmedFactors(ctx context.Context, userID uuid.UUID) ([]models.MFAFactor, error) {
+	factors, err := s.repo.ListFactors(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmed := factors[:0]
+	for _, factor := range factors {
+		if factor.ConfirmedAt != nil {
+			confirmed = append(confirmed, factor)
+		}
+	}
+
+	return confirmed, nil
+}
+
+func (s *mfaService) getFactor(ctx context.Context, userID, factorID uuid.UUID) (*models.MFAFactor, error) {
+	factor, err := s.repo.GetFactor(ctx, userID, factorID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMFAFactorNotFound) {
+			return nil, ErrMFAFactorNotFound
+		}
+		return nil, err
+	}
+	return factor, nil
+}
+
+// notify records the change and emails the account owner so an unexpected
+// enrollment or removal does not go unnoticed.
+func (s *mfaService) notify(ctx context.Context, userID uuid.UUID, event models.AuditEventType, change, method string) error {
+	if err := s.audit.Record(ctx, RecordAuditEventParams{
+		Type:     event,
+		UserID:   &userID,
+		Metadata: map[string]any{"method": method},
+	}); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.emails.Send(ctx, user, "", mailer.TemplateMFAChanged, map[string]any{"Event": change, "Method": method})
+}
+
+// isWebAuthnRejection reports errors caused by the presented assertion, as
+// opposed to infrastructure failures; they count as a wrong code.
+func isWebAuthnRejection(err error) bool {
+	return errors.Is(err, ErrWebAuthnVerificationFailed) ||
+		errors.Is(err, ErrWebAuthnCeremonyInvalid) ||
+		errors.Is(err, ErrWebAuthnCredentialNotFound) ||
+		errors.Is(err, ErrWebAuthnSignCountRegression)
+}
+
+// appendAMR adds value to amr unless already present.
+func appendAMR(amr []string, value string) []string {
+	if slices.Contains(amr, value) {
+		return amr
+	}
+	return append(amr, value)
+}
+
+func (s *mfaService) encryptionKey() ([]byte, error) {
+	if s.cfg.MFAEncryptionKey == "" {
+		return nil, ErrMFANotConfigured
+	}
+	return utils.ParseEncryptionKey(s.cfg.MFAEncryptionKey)
+}
+
+// generateBackupCode returns a code such as "k7qxm3fa-2pzr6hdw": easy to
+// type, 80 bits of entropy.
+func generateBackupCode() (string, error) {
+	buf := make([]byte, backupCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+	return encoded[:8] + "-" + encoded[8:], nil
+}
+
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,37 @@
+package services
+
+// OAuth 2.0 and OpenID Connect error codes returned to clients.
+const (
+	OAuthErrorInvalidRequest          = "invalid_request"
+	OAuthErrorInvalidClient           = "invalid_client"
+	OAuthErrorInvalidGrant            = "invalid_grant"
+	OAuthErrorUnauthorizedClient      = "unauthorized_client"
+	OAuthErrorAccessDenied            = "access_denied"
+	OAuthErrorUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrorInvalidScope            = "invalid_scope"
+	OAuthErrorUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrorInvalidTarget           = "invalid_target"
+	OAuthErrorAuthorizationPending    = "authorization_pending"
+	OAuthErrorSlowDown                = "slow_down"
+	OAuthErrorExpiredToken            = "expired_token"
+	OAuthErrorServerError             = "server_error"
+	OAuthErrorLoginRequired           = "login_required"
+)
+
+// OAuthError is a protocol error that is safe to expose to the client using
+// the error and error_description parameters.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/mailer"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	passwordResetEntropyBytes = 32
+
+	passwordResetKeyPrefix     = "password_reset:"
+	passwordResetUserKeyPrefix = "password_reset_user:"
+	passwordResetRateKeyPrefix = "password_reset_rate:"
+
+	PasswordResetPath = "/password/reset"
+)
+
+var ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+type PasswordResetService interface {
+	RequestReset(ctx context.Context, email, clientID string) error
+	ResetPassword(ctx context.Context, token, password string) error
+}
+
+type passwordResetService struct {
+	users  repositories.UserRepository
+	cache  CacheService
+	emails EmailService
+	logout LogoutService
+	audit  AuditService
+	cfg    config.SSOConfig
+}
+
+func NewPasswordResetService(
+	users repositories.UserRepository,
+	cache CacheService,
+	emails EmailService,
+	logout LogoutService,
+	audit AuditService,
+	cfg config.SSOConfig,
+) PasswordResetService {
+	return &passwordResetService{users: users, cache: cache, emails: emails, logout: logout, audit: audit, cfg: cfg}
+}
+
+// RequestReset mails a reset link when email belongs to an account that may
+// sign in. Unknown, disabled and rate-limited addresses are silently ignored
+// so callers learn nothing about which accounts exist.
+func (s *passwordResetService) RequestReset(ctx context.Context, email, clientID string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+
+	allowed, err := s.cache.SetIfAbsent(ctx, passwordResetRateKeyPrefix+utils.HashToken(strings.ToLower(email)), []byte("1"), s.cfg.EmailResendInterval)
+	if err != nil || !allowed {
+		return err
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Status == models.UserStatusDisabled {
+		return nil
+	}
+
+	token, err := utils.GenerateRandomToken(passwordResetEntropyBytes)
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is stored so a Redis dump cannot be replayed as links.
+	tokenHash := utils.HashToken(token)
+	if err := s.cache.Set(ctx, passwordResetKeyPrefix+tokenHash, []byte(user.ID.String()), s.cfg.PasswordResetTTL); err != nil {
+		return err
+	}
+	if err := s.cache.SetAdd(ctx, passwordResetUserKeyPrefix+user.ID.String(), tokenHash, s.cfg.PasswordResetTTL); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.cfg.IssuerURL, "/") + PasswordResetPath + "?" + url.Values{"token": {token}}.Encode()
+
+	return s.emails.Send(ctx, user, clientID, mailer.TemplatePasswordReset, map[string]any{"Link": link})
+}
+
+// ResetPassword redeems token, sets the new password and signs the user out
+// everywhere so a compromised session cannot outlive the reset.
+func (s *passwordResetService) ResetPassword(ctx context.Context, token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	if token == "" {
+		return ErrPasswordResetTokenInvalid
+	}
+
+	value, ok, err := s.cache.GetAndDelete(ctx, passwordResetKeyPrefix+utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPasswordResetTokenInvalid
+	}
+
+	userID, err := uuid.Parse(string(value))
+	if err != nil {
+		return ErrPasswordResetTokenInvalid
+	}
+
+	hash, err := utils.HashSensitiveValue(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.users.UpdatePasswordHash(ctx, userID, hash); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return ErrPasswordResetTokenInvalid
+		}
+		return err
+	}
+
+	if err := s.revokeOutstandingTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.logout.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.audit.Record(ctx, RecordAuditEventParams{
+		Type:   models.AuditEventPasswordReset,
+		UserID: &userID,
+	})
+}
+
+// revokeOutstandingTokens invalidates every other reset link mailed to the
+// user, so an older email cannot be used once the password has been reset.
+func (s *passwordResetService) revokeOutstandingTokens(ctx context.Context, userID uuid.UUID) error {
+	key := passwordResetUserKeyPrefix + userID.String()
+
+	hashes, err := s.cache.SetMembers(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := s.cache.Delete(ctx, passwordResetKeyPrefix+hash); err != nil {
+			return err
+		}
+	}
+
+	return s.cache.Delete(ctx, key)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const refreshTokenEntropyBytes = 32
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid, expired or revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+	ErrRefreshTokenForeign = errors.New("refresh token was issued to another client")
+)
+
+type IssueRefreshTokenParams struct {
+	ClientID string
+	Scopes   []string
+	Authentication
+}
+
+type RefreshTokenService interface {
+	Issue(ctx context.Context, params IssueRefreshTokenParams) (string, error)
+	Rotate(ctx context.Context, clientID, token string) (string, *models.RefreshToken, error)
+	Lookup(ctx context.Context, token string) (*models.RefreshToken, error)
+	Revoke(ctx context.Context, clientID, token string) (*models.RefreshToken, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type refreshTokenService struct {
+	repo  repositories.RefreshTokenRepository
+	audit AuditService
+	cfg   config.TokenTTLConfig
+}
+
+func NewRefreshTokenService(repo repositories.RefreshTokenRepository, audit AuditService, cfg config.TokenTTLConfig) RefreshTokenService {
+	return &refreshTokenService{repo: repo, audit: audit, cfg: cfg}
+}
+
+// Issue starts a new rotation family.
+func (s *refreshTokenService) Issue(ctx context.Context, params IssueRefreshTokenParams) (string, error) {
+	plain, _, err := s.create(ctx, uuid.New(), nil, params)
+	return plain, err
+}
+
+// Rotate redeems token for a successor in the same family. Presenting a token
+// that was already redeemed revokes the whole family, since either the
+// legitimate client or an attacker is holding a stolen copy.
+func (s *refreshTokenService) Rotate(ctx context.Context, clientID, token string) (string, *models.RefreshToken, error) {
+	record, err := s.repo.GetByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return "", nil, ErrRefreshTokenInvalid
+		}
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
+	if record.ClientID != clientID || record.RevokedAt != nil || !record.ExpiresAt.After(now) {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+
+	if record.ConsumedAt != nil {
+		return "", nil, s.handleReuse(ctx, record, now)
+	}
+
+	if err := s.repo.MarkConsumed(ctx, record.ID, now); err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenAlreadyConsumed) {
+			return "", nil, s.handleReuse(ctx, record, now)
+		}
+		return "", nil, err
+	}
+
+	plain, _, err := s.create(ctx, record.FamilyID, &record.ID, IssueRefreshTokenParams{
+		ClientID:       record.ClientID,
+		Scopes:         record.Scopes,
+		Authentication: refreshTokenAuthentication(record),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plain, record, nil
+}
+
+// Lookup returns the record for token if it can still be redeemed.
+func (s *refreshTokenService) Lookup(ctx context.Context, token string) (*models.RefreshToken, error) {
+	record, err := s.repo.GetByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+
+	if record.ConsumedAt != nil || record.RevokedAt != nil || !record.ExpiresAt.After(time.Now().UTC()) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	return record, nil
+}
+
+// Revoke ends the rotation family token belongs to, whether or not token
+// itself was already redeemed.
+func (s *refreshTokenService) Revoke(ctx context.Context, clientID, token string) (*models.RefreshToken, error) {
+	record, err := s.repo.GetByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+
+	if record.ClientID != clientID {
+		return nil, ErrRefreshTokenForeign
+	}
+
+	if err := s.repo.RevokeFamily(ctx, record.FamilyID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// RevokeSession revokes every refresh token issued within an IdP session.
+func (s *refreshTokenService) RevokeSession(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return s.repo.RevokeBySession(ctx, sessionID, time.Now().UTC())
+}
+
+// RevokeUser revokes every refresh token held by userID across all clients.
+func (s *refreshTokenService) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return s.repo.RevokeByUser(ctx, userID, time.Now().UTC())
+}
+
+func (s *refreshTokenService) handleReuse(ctx context.Context, record *models.RefreshToken, now time.Time) error {
+	if err := s.repo.RevokeFamily(ctx, record.FamilyID, now); err != nil {
+		return err
+	}
+
+	userID := record.UserID
+	if err := s.audit.Record(ctx, RecordAuditEventParams{
+		Type:     models.AuditEventRefreshTokenReuse,
+		UserID:   &userID,
+		ClientID: record.ClientID,
+		Metadata: map[string]any{
+			"family_id":        record.FamilyID.String(),
+			"refresh_token_id": record.ID.String(),
+		},
+	}); err != nil {
+		log.Printf("failed to record refresh token reuse for family %s: %v", record.FamilyID, err)
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func (s *refreshTokenService) create(ctx context.Context, familyID uuid.UUID, parentID *uuid.UUID, params IssueRefreshTokenParams) (string, *models.RefreshToken, error) {
+	plain, err := utils.GenerateRandomToken(refreshTokenEntropyBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	scopes := cloneStringSlice(params.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	record := &models.RefreshToken{
+		ID:        uuid.New(),
+		TokenHash: hashRefreshToken(plain),
+		FamilyID:  familyID,
+		ParentID:  parentID,
+		ClientID:  params.ClientID,
+		UserID:    params.UserID,
+		SessionID: params.SessionID,
+		Scopes:    scopes,
+		AuthTime:  params.AuthTime.UTC(),
+		AMR:       params.AMR,
+		ACR:       params.ACR,
+		ExpiresAt: time.Now().UTC().Add(s.cfg.RefreshToken),
+	}
+
+	if err := s.repo.Create(ctx, record); err != nil {
+		return "", nil, err
+	}
+
+	return plain, record, nil
+}
+
+func refreshTokenAuthentication(record *models.RefreshToken) Authentication {
+	return Authentication{
+		UserID:    record.UserID,
+		SessionID: record.SessionID,
+		AuthTime:  record.AuthTime,
+		AMR:       record.AMR,
+		ACR:       record.ACR,
+	}
+}
+
+// hashRefreshToken uses a plain digest: refresh tokens carry enough entropy
+// that a slow hash buys nothing, and lookups must be by exact hash.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
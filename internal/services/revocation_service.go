@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/mohammadhprp/passport/internal/models"
+)
+
+type RevocationService interface {
+	Revoke(ctx context.Context, caller *models.Client, token, tokenTypeHint string) error
+}
+
+type revocationService struct {
+	tokens        TokenService
+	refreshTokens RefreshTokenService
+	cache         CacheService
+	audit         AuditService
+}
+
+func NewRevocationService(tokens TokenService, refreshTokens RefreshTokenService, cache CacheService, audit AuditService) RevocationService {
+	return &revocationService{tokens: tokens, refreshTokens: refreshTokens, cache: cache, audit: audit}
+}
+
+// Revoke implements RFC 7009. Unknown, expired or already revoked tokens are
+// not an error; tokens issued to another client are refused.
+func (s *revocationService) Revoke(ctx context.Context, caller *models.Client, token, tokenTypeHint string) error {
+	// Public clients revoke with their client_id alone; a confidential
+	// client without a secret method has not authenticated at all.
+	if caller.Type == models.ClientTypeConfidential && !isAuthenticatedConfidential(caller) {
+		return newOAuthError(OAuthErrorInvalidClient, "confidential clients must authenticate with a secret")
+	}
+	if token == "" {
+		return newOAuthError(OAuthErrorInvalidRequest, "token is required")
+	}
+
+	revokers := []func(context.Context, *models.Client, string) (bool, error){
+		s.revokeRefreshToken,
+		s.revokeAccessToken,
+	}
+	if tokenTypeHint == TokenTypeHintAccessToken {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+
+	for _, revoke := range revokers {
+		found, err := revoke(ctx, caller, token)
+		if err != nil || found {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *revocationService) revokeRefreshToken(ctx context.Context, caller *models.Client, token string) (bool, error) {
+	record, err := s.refreshTokens.Revoke(ctx, caller.ClientID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRefreshTokenInvalid):
+			return false, nil
+		case errors.Is(err, ErrRefreshTokenForeign):
+			return true, newOAuthError(OAuthErrorUnauthorizedClient, err.Error())
+		default:
+			return false, err
+		}
+	}
+
+	userID := record.UserID
+	s.recordRevocation(ctx, RecordAuditEventParams{
+		Type:     models.AuditEventTokenRevoked,
+		UserID:   &userID,
+		ClientID: caller.ClientID,
+		Metadata: map[string]any{
+			"token_type": TokenTypeHintRefreshToken,
+			"family_id":  record.FamilyID.String(),
+		},
+	})
+
+	return true, nil
+}
+
+func (s *revocationService) revokeAccessToken(ctx context.Context, caller *models.Client, token string) (bool, error) {
+	claims, err := s.tokens.ValidateAccessToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAccessToken) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if claims.ClientID != caller.ClientID {
+		return true, newOAuthError(OAuthErrorUnauthorizedClient, "token was issued to another client")
+	}
+
+	if err := s.tokens.RevokeAccessToken(ctx, claims); err != nil {
+		return true, err
+	}
+
+	if err := s.cache.Delete(ctx, introspectionCacheKey(token)); err != nil {
+		log.Printf("failed to drop introspection cache for jti %s: %v", claims.JWTID, err)
+	}
+
+	s.recordRevocation(ctx, RecordAuditEventParams{
+		Type:     models.AuditEventTokenRevoked,
+		ClientID: caller.ClientID,
+		Metadata: map[string]any{
+			"token_type": TokenTypeHintAccessToken,
+			"jti":        claims.JWTID,
+			"sub":        claims.Subject,
+		},
+	})
+
+	return true, nil
+}
+
+func (s *revocationService) recordRevocation(ctx context.Context, params RecordAuditEventParams) {
+	if err := s.audit.Record(ctx, params); err != nil {
+		log.Printf("failed to record token revocation: %v", err)
+	}
+}
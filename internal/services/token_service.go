@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/keys"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+
+	TokenTypeBearer = "Bearer"
+
+	// accessTokenLeeway tolerates clock skew between us and token holders.
+	accessTokenLeeway = 30 * time.Second
+
+	revokedJTIKeyPrefix = "revoked_jti:"
+)
+
+type AccessTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  []string `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	JWTID     string   `json:"jti"`
+	ClientID  string   `json:"client_id"`
+	Scope     string   `json:"scope,omitempty"`
+}
+
+type IDTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        string   `json:"aud"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	AuthTime        int64    `json:"auth_time"`
+	ACR             string   `json:"acr,omitempty"`
+	AMR             []string `json:"amr,omitempty"`
+	Nonce           string   `json:"nonce,omitempty"`
+	AuthorizedParty string   `json:"azp"`
+	SessionID       string   `json:"sid,omitempty"`
+	UserClaims
+}
+
+// TokenResponse is the successful token endpoint response (RFC 6749 5.1).
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type AuthorizationCodeGrant struct {
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+type RefreshTokenGrant struct {
+	RefreshToken string
+	Scope        string
+}
+
+// ClientCredentialsGrant carries the requested scope and the APIs the token
+// is meant for, given either as audience names or RFC 8707 resource URIs.
+type ClientCredentialsGrant struct {
+	Scope     string
+	Audience  string
+	Resources []string
+}
+
+var ErrInvalidAccessToken = errors.New("access token is invalid or expired")
+
+type TokenService interface {
+	ExchangeAuthorizationCode(ctx context.Context, client *models.Client, grant AuthorizationCodeGrant) (*TokenResponse, error)
+	ExchangeRefreshToken(ctx context.Context, client *models.Client, grant RefreshTokenGrant) (*TokenResponse, error)
+	ExchangeClientCredentials(ctx context.Context, client *models.Client, grant ClientCredentialsGrant) (*TokenResponse, error)
+	ExchangeDeviceCode(ctx context.Context, client *models.Client, deviceCode string) (*TokenResponse, error)
+	ValidateAccessToken(ctx context.Context, token string) (*AccessTokenClaims, error)
+	RevokeAccessToken(ctx context.Context, claims *AccessTokenClaims) error
+}
+
+type tokenService struct {
+	authorizations AuthorizationService
+	devices        DeviceAuthorizationService
+	refreshTokens  RefreshTokenService
+	logout         LogoutService
+	users          repositories.UserRepository
+	keys           keys.Manager
+	cache          CacheService
+	cfg            config.SSOConfig
+}
+
+// userTokens describes the tokens to mint for an end-user grant.
+type userTokens struct {
+	client       *models.Client
+	auth         Authentication
+	scopes       []string
+	nonce        string
+	refreshToken string
+}
+
+func NewTokenService(
+	authorizations AuthorizationService,
+	devices DeviceAuthorizationService,
+	refreshTokens RefreshTokenService,
+	logout LogoutService,
+	users repositories.UserRepository,
+	keys keys.Manager,
+	cache CacheService,
+	cfg config.SSOConfig,
+) TokenService {
+	return &tokenService{
+		authorizations: authorizations,
+		devices:        devices,
+		refreshTokens:  refreshTokens,
+		logout:         logout,
+		users:          users,
+		keys:           keys,
+		cache:          cache,
+		cfg:            cfg,
+	}
+}
+
+func (s *tokenService) ExchangeAuthorizationCode(ctx context.Context, client *models.Client, grant AuthorizationCodeGrant) (*TokenResponse, error) {
+	if grant.Code == "" {
+		return nil, newOAuthError(OAuthErrorInvalidRequest, "code is required")
+	}
+
+	code, err := s.authorizations.ConsumeCode(ctx, grant.Code)
+	if err != nil {
+		if errors.Is(err, ErrAuthorizationCodeNotFound) {
+			return nil, newOAuthError(OAuthErrorInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+
+	if code.ClientID != client.ClientID {
+		return nil, newOAuthError(OAuthErrorInvalidGrant, "code was issued to another client")
+	}
+	if code.RedirectURI != grant.RedirectURI {
+		return nil, newOAuthError(OAuthErrorInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if err := verifyCodeVerifier(code, grant.CodeVerifier); err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.refreshTokens.Issue(ctx, IssueRefreshTokenParams{
+		ClientID:       client.ClientID,
+		Scopes:         code.Scopes,
+		Authentication: code.Authentication,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueUserTokens(ctx, userTokens{
+		client:       client,
+		auth:         code.Authentication,
+		scopes:       code.Scopes,
+		nonce:        code.Nonce,
+		refreshToken: refreshToken,
+	})
+}
+
+func (s *tokenService) ExchangeRefreshToken(ctx context.Context, client *models.Client, grant RefreshTokenGrant) (*TokenResponse, error) {
+	if grant.RefreshToken == "" {
+		return nil, newOAuthError(OAuthErrorInvalidRequest, "refresh_token is required")
+	}
+
+	refreshToken, previous, err := s.refreshTokens.Rotate(ctx, client.ClientID, grant.RefreshToken)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenInvalid) || errors.Is(err, ErrRefreshTokenReused) {
+			return nil, newOAuthError(OAuthErrorInvalidGrant, err.Error())
+		}
+		return nil, err
+	}
+
+	// The access token may be narrowed, but the refresh token keeps the
+	// originally granted scopes (RFC 6749 section 6).
+	scopes := previous.Scopes
+	if requested := sanitizeScopes(strings.Fields(grant.Scope)); len(requested) > 0 {
+		for _, scope := range requested {
+			if !containsString(previous.Scopes, scope) {
+				return nil, newOAuthError(OAuthErrorInvalidScope, "scope "+scope+" was not originally granted")
+			}
+		}
+		scopes = requested
+	}
+
+	return s.issueUserTokens(ctx, userTokens{
+		client:       client,
+		auth:         refreshTokenAuthentication(previous),
+		scopes:       scopes,
+		refreshToken: refreshToken,
+	})
+}
+
+// ExchangeClientCredentials issues a machine token whose subject is the client
+// itself. No ID token or refresh token is ever returned for this grant.
+func (s *tokenService) ExchangeClientCredentials(ctx context.Context, client *models.Client, grant ClientCredentialsGrant) (*TokenResponse, error) {
+	if !isAuthenticatedConfidential(client) {
+		return nil, newOAuthError(OAuthErrorUnauthorizedClient, "client_credentials requires an authenticated confidential client")
+	}
+
+	scopes := cloneStringSlice(client.Scopes)
+	if requested := sanitizeScopes(strings.Fields(grant.Scope)); len(requested) > 0 {
+		for _, scope := range requested {
+			if !containsString(client.Scopes, scope) {
+				return nil, newOAuthError(OAuthErrorInvalidScope, "scope "+scope+" is not allowed for this client")
+			}
+		}
+		scopes = requested
+	}
+
+	audience, err := resolveAudience(client, grant)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.issueAccessToken(ctx, time.Now().UTC(), client.ClientID, client.ClientID, audience, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   TokenTypeBearer,
+		ExpiresIn:   int64(s.cfg.Tokens.AccessToken.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func (s *tokenService) ExchangeDeviceCode(ctx context.Context, client *models.Client, deviceCode string) (*TokenResponse, error) {
+	authorization, err := s.devices.Poll(ctx, client.ClientID, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.refreshTokens.Issue(ctx, IssueRefreshTokenParams{
+		ClientID:       client.ClientID,
+		Scopes:         authorization.Scopes,
+		Authentication: authorization.Authentication,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueUserTokens(ctx, userTokens{
+		client:       client,
+		auth:         authorization.Authentication,
+		scopes:       authorization.Scopes,
+		refreshToken: refreshToken,
+	})
+}
+
+func (s *tokenService) issueUserTokens(ctx context.Context, tokens userTokens) (*TokenResponse, error) {
+	now := time.Now().UTC()
+	subject := tokens.auth.UserID.String()
+	clientID := tokens.client.ClientID
+
+	accessToken, err := s.issueAccessToken(ctx, now, subject, clientID, []string{clientID}, tokens.scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    TokenTypeBearer,
+		ExpiresIn:    int64(s.cfg.Tokens.AccessToken.Seconds()),
+		RefreshToken: tokens.refreshToken,
+		Scope:        strings.Join(tokens.scopes, " "),
+	}
+
+	if containsString(tokens.scopes, ScopeOpenID) {
+		user, err := s.users.GetByID(ctx, tokens.auth.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		idToken, err := s.keys.Sign(ctx, IDTokenClaims{
+			Issuer:          s.issuer(),
+			Subject:         subject,
+			Audience:        clientID,
+			ExpiresAt:       now.Add(s.cfg.Tokens.IDToken).Unix(),
+			IssuedAt:        now.Unix(),
+			AuthTime:        tokens.auth.AuthTime.Unix(),
+			ACR:             tokens.auth.ACR,
+			AMR:             tokens.auth.AMR,
+			Nonce:           tokens.nonce,
+			AuthorizedParty: clientID,
+			SessionID:       tokens.auth.SessionID,
+			UserClaims:      ReleaseUserClaims(user, tokens.scopes),
+		})
+		if err != nil {
+			return nil, err
+		}
+		response.IDToken = idToken
+	}
+
+	if tokens.auth.SessionID != "" {
+		if err := s.logout.TrackParticipant(ctx, tokens.auth.SessionID, clientID); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// ValidateAccessToken verifies the signature and registered claims of an
+// access token issued by this server.
+func (s *tokenService) ValidateAccessToken(ctx context.Context, token string) (*AccessTokenClaims, error) {
+	if token == "" {
+		return nil, ErrInvalidAccessToken
+	}
+
+	var claims AccessTokenClaims
+	if err := s.keys.Verify(ctx, token, &claims); err != nil {
+		if errors.Is(err, keys.ErrInvalidToken) || errors.Is(err, keys.ErrUnknownKey) {
+			return nil, ErrInvalidAccessToken
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case claims.Issuer != s.issuer(),
+		claims.JWTID == "",
+		claims.ClientID == "",
+		now.After(time.Unix(claims.ExpiresAt, 0).Add(accessTokenLeeway)),
+		now.Add(accessTokenLeeway).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, ErrInvalidAccessToken
+	}
+
+	_, revoked, err := s.cache.Get(ctx, revokedJTIKeyPrefix+claims.JWTID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidAccessToken
+	}
+
+	return &claims, nil
+}
+
+// RevokeAccessToken denylists the token's jti until the token would have
+// expired on its own.
+func (s *tokenService) RevokeAccessToken(ctx context.Context, claims *AccessTokenClaims) error {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0).Add(accessTokenLeeway))
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.cache.Set(ctx, revokedJTIKeyPrefix+claims.JWTID, []byte(claims.ClientID), ttl)
+}
+
+func (s *tokenService) issueAccessToken(ctx context.Context, now time.Time, subject, clientID string, audience, scopes []string) (string, error) {
+	return s.keys.Sign(ctx, AccessTokenClaims{
+		Issuer:    s.issuer(),
+		Subject:   subject,
+		Audience:  audience,
+		ExpiresAt: now.Add(s.cfg.Tokens.AccessToken).Unix(),
+		IssuedAt:  now.Unix(),
+		JWTID:     uuid.NewString(),
+		ClientID:  clientID,
+		Scope:     strings.Join(scopes, " "),
+	})
+}
+
+func (s *tokenService) issuer() string {
+	return strings.TrimRight(s.cfg.IssuerURL, "/")
+}
+
+// resolveAudience merges the audience and resource parameters. Resources must
+// be absolute URIs without a fragment, and every value must be the client
+// itself or one of its allowed audiences; without either the client itself is
+// the audience.
+func resolveAudience(client *models.Client, grant ClientCredentialsGrant) ([]string, error) {
+	audience := make([]string, 0, len(grant.Resources)+1)
+
+	for _, resource := range grant.Resources {
+		parsed, err := url.Parse(resource)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return nil, newOAuthError(OAuthErrorInvalidTarget, "resource must be an absolute uri without a fragment")
+		}
+		if !audienceAllowed(client, resource) {
+			return nil, newOAuthError(OAuthErrorInvalidTarget, "resource is not allowed for this client")
+		}
+		if !containsString(audience, resource) {
+			audience = append(audience, resource)
+		}
+	}
+
+	if value := strings.TrimSpace(grant.Audience); value != "" && !containsString(audience, value) {
+		if !audienceAllowed(client, value) {
+			return nil, newOAuthError(OAuthErrorInvalidTarget, "audience is not allowed for this client")
+		}
+		audience = append(audience, value)
+	}
+
+	if len(audience) == 0 {
+		audience = append(audience, client.ClientID)
+	}
+
+	return audience, nil
+}
+
+func audienceAllowed(client *models.Client, value string) bool {
+	return value == client.ClientID || containsString(client.AllowedAudiences, value)
+}
+
+// verifyCodeVerifier applies the RFC 7636 S256 check. A verifier sent for a
+// code that was issued without a challenge is rejected as well.
+func verifyCodeVerifier(code *AuthorizationCode, verifier string) error {
+	if code.CodeChallenge == "" {
+		if verifier != "" {
+			return newOAuthError(OAuthErrorInvalidGrant, "code_verifier sent for a code issued without code_challenge")
+		}
+		return nil
+	}
+
+	if !isValidPKCEValue(verifier, 43, 128) {
+		return newOAuthError(OAuthErrorInvalidGrant, "missing or malformed code_verifier")
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(computed), []byte(code.CodeChallenge)) != 1 {
+		return newOAuthError(OAuthErrorInvalidGrant, "code_verifier does not match code_challenge")
+	}
+
+	return nil
+}
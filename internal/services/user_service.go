@@ -3,6 +3,9 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 
@@ -11,17 +14,36 @@ import (
 	"github.com/mohammadhprp/passport/internal/utils"
 )
 
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 128
+)
+
+var (
+	ErrInvalidPassword = errors.New("password must be between 8 and 128 characters")
+
+	// ErrInvalidCredentials covers both unknown emails and wrong passwords so
+	// login responses never reveal which accounts exist.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrUserDisabled       = errors.New("account is disabled")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+)
+
 var (
-	ErrInvalidUserStatus = errors.New("invalid user status")
-	ErrInvalidPassword   = errors.New("password must not be empty")
+	dummyPasswordHashOnce sync.Once
+	dummyPasswordHash     string
 )
 
 type CreateUserParams struct {
-	Email         string
-	Password      string
-	MFAFactors    []string
-	Status        models.UserStatus
-	EmailVerified bool
+	Email      string
+	Password   string
+	Name       string
+	GivenName  string
+	FamilyName string
+	Locale     string
+	// ClientID brands the verification email for the app the user signed up
+	// through.
+	ClientID string
 }
 
 type ListUsersFilter struct {
@@ -33,27 +55,26 @@ type UserService interface {
 	CreateUser(ctx context.Context, params CreateUserParams) (*models.User, error)
 	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
 	ListUsers(ctx context.Context, filter ListUsersFilter) ([]models.User, error)
+	AuthenticateUser(ctx context.Context, email, password string) (*models.User, error)
 }
 
 type userService struct {
-	repo repositories.UserRepository
+	repo         repositories.UserRepository
+	transactor   repositories.Transactor
+	verification EmailVerificationService
 }
 
-func NewUserService(repo repositories.UserRepository) UserService {
-	return &userService{repo: repo}
+func NewUserService(
+	repo repositories.UserRepository,
+	transactor repositories.Transactor,
+	verification EmailVerificationService,
+) UserService {
+	return &userService{repo: repo, transactor: transactor, verification: verification}
 }
 
 func (s *userService) CreateUser(ctx context.Context, params CreateUserParams) (*models.User, error) {
-	if params.Password == "" {
-		return nil, ErrInvalidPassword
-	}
-
-	status := params.Status
-	if status == "" {
-		status = models.UserStatusPending
-	}
-	if !isValidStatus(status) {
-		return nil, ErrInvalidUserStatus
+	if err := validatePassword(params.Password); err != nil {
+		return nil, err
 	}
 
 	hash, err := hashPassword(params.Password)
@@ -64,17 +85,25 @@ func (s *userService) CreateUser(ctx context.Context, params CreateUserParams) (
 	user := &models.User{
 		ID:            uuid.New(),
 		Email:         params.Email,
-		EmailVerified: params.EmailVerified,
+		EmailVerified: false,
 		PasswordHash:  hash,
-		MFAFactors:    cloneStringSlice(params.MFAFactors),
-		Status:        status,
+		Name:          params.Name,
+		GivenName:     params.GivenName,
+		FamilyName:    params.FamilyName,
+		Locale:        params.Locale,
+		Status:        models.UserStatusPending,
 	}
 
-	if user.MFAFactors == nil {
-		user.MFAFactors = []string{}
-	}
-
-	if err := s.repo.Create(ctx, user); err != nil {
+	// New accounts always start pending and unverified; only the
+	// verification link activates them. The email is queued in the same
+	// transaction, so it goes out exactly when the account exists.
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, user); err != nil {
+			return err
+		}
+		return s.verification.SendVerification(ctx, user, params.ClientID)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -99,13 +128,78 @@ func (s *userService) ListUsers(ctx context.Context, filter ListUsersFilter) ([]
 	return s.repo.List(ctx, offset, limit)
 }
 
-func isValidStatus(status models.UserStatus) bool {
-	switch status {
-	case models.UserStatusPending, models.UserStatusActive, models.UserStatusDisabled:
-		return true
-	default:
-		return false
+// AuthenticateUser verifies an email and password. Account state is only
+// reported once the password has been proven, and unknown emails cost the
+// same hash verification as known ones.
+func (s *userService) AuthenticateUser(ctx context.Context, email, password string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			verifyDummyPassword(password)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	match, needsRehash, err := utils.VerifySensitiveValue(password, user.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := CheckSignInAllowed(user); err != nil {
+		return nil, err
+	}
+
+	if needsRehash {
+		if hash, err := hashPassword(password); err == nil {
+			if err := s.repo.UpdatePasswordHash(ctx, user.ID, hash); err == nil {
+				user.PasswordHash = hash
+			}
+		}
+	}
+
+	return user, nil
+}
+
+// CheckSignInAllowed reports whether the account status permits a sign-in,
+// whatever method proved the user's identity.
+func CheckSignInAllowed(user *models.User) error {
+	switch {
+	case user.Status == models.UserStatusDisabled:
+		return ErrUserDisabled
+	case user.Status == models.UserStatusPending && !user.EmailVerified:
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// verifyDummyPassword spends the time of a real password check so unknown
+// emails cannot be told apart by response latency.
+func verifyDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = hashPassword("passport-dummy-password")
+	})
+	if dummyPasswordHash != "" {
+		_, _, _ = utils.VerifySensitiveValue(password, dummyPasswordHash)
+	}
+}
+
+// validatePassword applies the password policy. The upper bound keeps
+// Argon2id hashing cheap enough to not be a denial-of-service lever.
+func validatePassword(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < minPasswordLength || length > maxPasswordLength || strings.TrimSpace(password) == "" {
+		return ErrInvalidPassword
 	}
+	return nil
 }
 
 func hashPassword(password string) (string, error) {
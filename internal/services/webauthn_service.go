@@ -0,0 +1,372 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/models"
+	"github.com/mohammadhprp/passport/internal/repositories"
+	"github.com/mohammadhprp/passport/internal/utils"
+	"github.com/mohammadhprp/passport/internal/webauthn"
+)
+
+const (
+	webAuthnCeremonyKeyPrefix = "webauthn_ceremony:"
+
+	webAuthnCeremonyRegistration   = "registration"
+	webAuthnCeremonyAuthentication = "authentication"
+)
+
+var (
+	ErrWebAuthnCeremonyInvalid     = errors.New("webauthn challenge is invalid or expired")
+	ErrWebAuthnCredentialNotFound  = errors.New("webauthn credential not found")
+	ErrWebAuthnCredentialExists    = errors.New("webauthn credential is already registered")
+	ErrWebAuthnVerificationFailed  = errors.New("webauthn verification failed")
+	ErrWebAuthnSignCountRegression = errors.New("webauthn credential may be cloned")
+)
+
+// WebAuthnLogin identifies the user behind a verified assertion.
+type WebAuthnLogin struct {
+	UserID       uuid.UUID
+	CredentialID uuid.UUID
+	UserVerified bool
+	// AMR is the method reference for the key: hwk, or swk for passkeys
+	// that may be synced.
+	AMR string
+}
+
+// webAuthnCeremony is the server-side state of one ceremony, stored under
+// its challenge. UserID is nil for discoverable logins.
+type webAuthnCeremony struct {
+	Ceremony         string     `json:"ceremony"`
+	UserID           *uuid.UUID `json:"user_id,omitempty"`
+	UserVerification string     `json:"user_verification"`
+}
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*webauthn.CreationOptions, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, label string, response *webauthn.RegistrationResponse) (*models.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context, userID *uuid.UUID) (*webauthn.RequestOptions, error)
+	FinishLogin(ctx context.Context, userID *uuid.UUID, response *webauthn.AssertionResponse) (*WebAuthnLogin, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+	DeleteUserCredentials(ctx context.Context, userID uuid.UUID) error
+}
+
+type webAuthnService struct {
+	repo  repositories.WebAuthnCredentialRepository
+	users repositories.UserRepository
+	cache CacheService
+	audit AuditService
+	rp    *webauthn.RelyingParty
+	rpErr error
+	cfg   config.SSOConfig
+}
+
+// NewWebAuthnService derives the relying party from cfg.IssuerURL. An
+// unusable issuer URL surfaces as an error from every ceremony.
+func NewWebAuthnService(
+	repo repositories.WebAuthnCredentialRepository,
+	users repositories.UserRepository,
+	cache CacheService,
+	audit AuditService,
+	cfg config.SSOConfig,
+) WebAuthnService {
+	rp, err := webauthn.NewRelyingParty(cfg.IssuerURL, cfg.MFAIssuer)
+	return &webAuthnService{repo: repo, users: users, cache: cache, audit: audit, rp: rp, rpErr: err, cfg: cfg}
+}
+
+// BeginRegistration returns creation options for a new credential of the
+// user. Existing credentials are excluded so an authenticator is not
+// registered twice.
+func (s *webAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*webauthn.CreationOptions, error) {
+	if s.rpErr != nil {
+		return nil, s.rpErr
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.startCeremony(ctx, webAuthnCeremony{
+		Ceremony:         webAuthnCeremonyRegistration,
+		UserID:           &user.ID,
+		UserVerification: webauthn.UserVerificationPreferred,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.Email
+	}
+
+	// The user handle is the account UUID: stable and free of personal data.
+	options := s.rp.CreationOptions(
+		webauthn.User{ID: user.ID[:], Name: user.Email, DisplayName: displayName},
+		challenge,
+		credentialDescriptors(credentials),
+		s.cfg.MFAChallengeTTL,
+	)
+
+	return &options, nil
+}
+
+func (s *webAuthnService) FinishRegistration(ctx context.Context, userID uuid.UUID, label string, response *webauthn.RegistrationResponse) (*models.WebAuthnCredential, error) {
+	if s.rpErr != nil {
+		return nil, s.rpErr
+	}
+
+	challenge, ceremony, err := s.consumeCeremony(ctx, response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.Ceremony != webAuthnCeremonyRegistration || ceremony.UserID == nil || *ceremony.UserID != userID {
+		return nil, ErrWebAuthnCeremonyInvalid
+	}
+
+	verified, err := s.rp.VerifyRegistration(response, challenge, ceremony.UserVerification)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnVerificationFailed, err)
+	}
+
+	if _, err := s.repo.GetByCredentialID(ctx, verified.ID); err == nil {
+		return nil, ErrWebAuthnCredentialExists
+	} else if !errors.Is(err, repositories.ErrWebAuthnCredentialNotFound) {
+		return nil, err
+	}
+
+	aaguid, err := uuid.FromBytes(verified.AAGUID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnVerificationFailed, err)
+	}
+
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = "Security key"
+	}
+
+	transports := verified.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+
+	credential := &models.WebAuthnCredential{
+		ID:             uuid.New(),
+		UserID:         userID,
+		CredentialID:   verified.ID,
+		PublicKey:      verified.PublicKey,
+		Algorithm:      verified.Algorithm,
+		SignCount:      int64(verified.SignCount),
+		Transports:     transports,
+		AAGUID:         aaguid,
+		Label:          label,
+		BackupEligible: verified.BackupEligible,
+		BackupState:    verified.BackupState,
+	}
+	if err := s.repo.Create(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// BeginLogin returns request options. With a user (second factor) the
+// user's credentials are listed; without one the browser offers any
+// discoverable credential and user verification is required, since the key
+// is then the only factor.
+func (s *webAuthnService) BeginLogin(ctx context.Context, userID *uuid.UUID) (*webauthn.RequestOptions, error) {
+	if s.rpErr != nil {
+		return nil, s.rpErr
+	}
+
+	ceremony := webAuthnCeremony{
+		Ceremony:         webAuthnCeremonyAuthentication,
+		UserID:           userID,
+		UserVerification: webauthn.UserVerificationRequired,
+	}
+
+	var allow []webauthn.CredentialDescriptor
+	if userID != nil {
+		credentials, err := s.repo.ListByUser(ctx, *userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(credentials) == 0 {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+		allow = credentialDescriptors(credentials)
+		ceremony.UserVerification = webauthn.UserVerificationPreferred
+	}
+
+	challenge, err := s.startCeremony(ctx, ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	options := s.rp.RequestOptions(challenge, allow, ceremony.UserVerification, s.cfg.MFAChallengeTTL)
+	return &options, nil
+}
+
+// FinishLogin verifies an assertion. userID must match the one BeginLogin
+// was called with; nil for discoverable logins.
+func (s *webAuthnService) FinishLogin(ctx context.Context, userID *uuid.UUID, response *webauthn.AssertionResponse) (*WebAuthnLogin, error) {
+	if s.rpErr != nil {
+		return nil, s.rpErr
+	}
+
+	challenge, ceremony, err := s.consumeCeremony(ctx, response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.Ceremony != webAuthnCeremonyAuthentication || !sameUser(ceremony.UserID, userID) {
+		return nil, ErrWebAuthnCeremonyInvalid
+	}
+
+	credentialID, err := response.CredentialID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnVerificationFailed, err)
+	}
+
+	credential, err := s.repo.GetByCredentialID(ctx, credentialID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrWebAuthnCredentialNotFound) {
+			return nil, ErrWebAuthnCredentialNotFound
+		}
+		return nil, err
+	}
+
+	if userID != nil && credential.UserID != *userID {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	if userID == nil {
+		// Discoverable credentials return the user handle; it must name the
+		// credential's owner.
+		handle, err := response.UserHandle()
+		if err != nil || !bytes.Equal(handle, credential.UserID[:]) {
+			return nil, fmt.Errorf("%w: user handle mismatch", ErrWebAuthnVerificationFailed)
+		}
+	}
+
+	assertion, err := s.rp.VerifyAssertion(response, challenge, credential.PublicKey, uint32(credential.SignCount), ceremony.UserVerification)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegression) {
+			return nil, s.reportSignCountRegression(ctx, credential)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnVerificationFailed, err)
+	}
+
+	if err := s.repo.UpdateUsage(ctx, credential.ID, int64(assertion.SignCount), assertion.BackupState, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	amr := AMRHardwareKey
+	if credential.BackupEligible {
+		amr = AMRSoftwareKey
+	}
+
+	return &WebAuthnLogin{
+		UserID:       credential.UserID,
+		CredentialID: credential.ID,
+		UserVerified: assertion.UserVerified,
+		AMR:          amr,
+	}, nil
+}
+
+func (s *webAuthnService) ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *webAuthnService) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.repo.Delete(ctx, userID, id); err != nil {
+		if errors.Is(err, repositories.ErrWebAuthnCredentialNotFound) {
+			return ErrWebAuthnCredentialNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *webAuthnService) DeleteUserCredentials(ctx context.Context, userID uuid.UUID) error {
+	return s.repo.DeleteByUser(ctx, userID)
+}
+
+// reportSignCountRegression records a possible cloned authenticator. The
+// assertion is refused; the credential stays so the user can investigate.
+func (s *webAuthnService) reportSignCountRegression(ctx context.Context, credential *models.WebAuthnCredential) error {
+	if err := s.audit.Record(ctx, RecordAuditEventParams{
+		Type:   models.AuditEventWebAuthnSignCountRegression,
+		UserID: &credential.UserID,
+		Metadata: map[string]any{
+			"credential_id": credential.ID.String(),
+			"sign_count":    credential.SignCount,
+		},
+	}); err != nil {
+		return err
+	}
+	return ErrWebAuthnSignCountRegression
+}
+
+func (s *webAuthnService) startCeremony(ctx context.Context, ceremony webAuthnCeremony) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	key := webAuthnCeremonyKeyPrefix + utils.HashToken(webauthn.EncodeBase64URL(challenge))
+	if err := s.cache.SetJSON(ctx, key, ceremony, s.cfg.MFAChallengeTTL); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// consumeCeremony loads and deletes the ceremony named by the challenge the
+// browser signed, so each challenge is accepted at most once.
+func (s *webAuthnService) consumeCeremony(ctx context.Context, challengeOf func() ([]byte, error)) ([]byte, *webAuthnCeremony, error) {
+	challenge, err := challengeOf()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrWebAuthnVerificationFailed, err)
+	}
+
+	var ceremony webAuthnCeremony
+	key := webAuthnCeremonyKeyPrefix + utils.HashToken(webauthn.EncodeBase64URL(challenge))
+	found, err := s.cache.GetAndDeleteJSON(ctx, key, &ceremony)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, ErrWebAuthnCeremonyInvalid
+	}
+
+	return challenge, &ceremony, nil
+}
+
+func credentialDescriptors(credentials []models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, webauthn.NewCredentialDescriptor(credential.CredentialID, credential.Transports))
+	}
+	return descriptors
+}
+
+func sameUser(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
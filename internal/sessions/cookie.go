@@ -0,0 +1,68 @@
+package sessions
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mohammadhprp/passport/internal/config"
+)
+
+// SetCookie writes the session cookie. Its lifetime is capped by both
+// cfg.MaxAge and the session's absolute expiry; a zero MaxAge makes it a
+// browser-session cookie.
+func SetCookie(w http.ResponseWriter, cfg config.CookieConfig, token string, session *Session) {
+	cookie := &http.Cookie{
+		Name:     cfg.Name,
+		Value:    token,
+		Domain:   cfg.Domain,
+		Path:     cfg.Path,
+		Secure:   cfg.Secure,
+		HttpOnly: cfg.HTTPOnly,
+		SameSite: parseSameSite(cfg.SameSite),
+	}
+
+	if cfg.MaxAge > 0 {
+		maxAge := min(cfg.MaxAge, time.Until(session.ExpiresAt))
+		cookie.MaxAge = max(int(maxAge/time.Second), 1)
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// ClearCookie expires the session cookie. Name, Domain and Path must match
+// the values used when the cookie was set or browsers keep it.
+func ClearCookie(w http.ResponseWriter, cfg config.CookieConfig) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cfg.Name,
+		Value:    "",
+		Domain:   cfg.Domain,
+		Path:     cfg.Path,
+		MaxAge:   -1,
+		Secure:   cfg.Secure,
+		HttpOnly: cfg.HTTPOnly,
+		SameSite: parseSameSite(cfg.SameSite),
+	})
+}
+
+// CookieToken returns the session token sent by the browser, if any.
+func CookieToken(r *http.Request, cfg config.CookieConfig) string {
+	cookie, err := r.Cookie(cfg.Name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	case "lax":
+		return http.SameSiteLaxMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
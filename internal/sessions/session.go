@@ -0,0 +1,409 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mohammadhprp/passport/internal/config"
+	"github.com/mohammadhprp/passport/internal/services"
+	"github.com/mohammadhprp/passport/internal/utils"
+)
+
+const (
+	sessionIDEntropyBytes     = 16
+	sessionSecretEntropyBytes = 32
+
+	sessionKeyPrefix      = "session:"
+	userSessionsKeyPrefix = "user_sessions:"
+
+	// A session is a Redis hash. The fields fixed at login sit in one JSON
+	// field; the ones that change while it is in use get their own, so
+	// concurrent updates never overwrite each other.
+	sessionDataField         = "data"
+	sessionLastSeenField     = "last_seen"
+	sessionClientFieldPrefix = "client:"
+
+	// touchInterval limits how often a resolved session is written back to
+	// Redis just to slide its idle expiry.
+	touchInterval = time.Minute
+)
+
+var ErrSessionNotFound = errors.New("session is invalid or expired")
+
+// Session is an authenticated IdP session. ID is public and travels in ID
+// tokens as sid; the cookie additionally carries a secret of which only the
+// hash is stored.
+type Session struct {
+	ID         string    `json:"id"`
+	SecretHash string    `json:"secret_hash"`
+	UserID     uuid.UUID `json:"user_id"`
+	AuthTime   time.Time `json:"auth_time"`
+	AMR        []string  `json:"amr,omitempty"`
+	ACR        string    `json:"acr,omitempty"`
+	Clients    []string  `json:"clients,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// sessionData is the part of a Session stored in its data field.
+type sessionData struct {
+	ID         string    `json:"id"`
+	SecretHash string    `json:"secret_hash"`
+	UserID     uuid.UUID `json:"user_id"`
+	AuthTime   time.Time `json:"auth_time"`
+	AMR        []string  `json:"amr,omitempty"`
+	ACR        string    `json:"acr,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type CreateParams struct {
+	UserID    uuid.UUID
+	AuthTime  time.Time
+	AMR       []string
+	ACR       string
+	IPAddress string
+	UserAgent string
+}
+
+// ReauthenticateParams describe a fresh authentication of a session's user.
+type ReauthenticateParams struct {
+	AuthTime time.Time
+	AMR      []string
+	ACR      string
+}
+
+// Store keeps sessions in Redis. Sessions expire after SessionIdleTimeout
+// without use and never outlive SessionTTL from creation.
+type Store interface {
+	Create(ctx context.Context, params CreateParams) (*Session, string, error)
+	Resolve(ctx context.Context, token string) (*Session, error)
+	Get(ctx context.Context, id string) (*Session, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]Session, error)
+	SessionIDsByUser(ctx context.Context, userID uuid.UUID) ([]string, error)
+	Reauthenticate(ctx context.Context, id string, params ReauthenticateParams) (*Session, error)
+	AddClient(ctx context.Context, id, clientID string) error
+	Terminate(ctx context.Context, id string) ([]string, error)
+}
+
+type store struct {
+	cache services.CacheService
+	cfg   config.SSOConfig
+}
+
+func NewStore(cache services.CacheService, cfg config.SSOConfig) Store {
+	return &store{cache: cache, cfg: cfg}
+}
+
+// Create starts a session and returns it with the opaque cookie token.
+func (s *store) Create(ctx context.Context, params CreateParams) (*Session, string, error) {
+	id, err := utils.GenerateRandomToken(sessionIDEntropyBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := utils.GenerateRandomToken(sessionSecretEntropyBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	authTime := params.AuthTime.UTC()
+	if authTime.IsZero() {
+		authTime = now
+	}
+
+	session := &Session{
+		ID:         id,
+		SecretHash: hashSecret(secret),
+		UserID:     params.UserID,
+		AuthTime:   authTime,
+		AMR:        params.AMR,
+		ACR:        params.ACR,
+		IPAddress:  params.IPAddress,
+		UserAgent:  params.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.cfg.SessionTTL),
+	}
+
+	fields, err := encodeSession(session)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.cache.HashSet(ctx, sessionKeyPrefix+id, fields, time.Until(s.deadline(session))); err != nil {
+		return nil, "", err
+	}
+	if err := s.cache.SetAdd(ctx, userSessionsKeyPrefix+session.UserID.String(), id, s.cfg.SessionTTL); err != nil {
+		return nil, "", err
+	}
+
+	return session, id + "." + secret, nil
+}
+
+// Resolve looks up the session behind a cookie token and slides its idle
+// expiry.
+func (s *store) Resolve(ctx context.Context, token string) (*Session, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrSessionNotFound
+	}
+
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(session.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrSessionNotFound
+	}
+
+	// Only last_seen is written, and only while the session exists, so a
+	// touch racing a logout or AddClient loses nothing.
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt) >= touchInterval {
+		session.LastSeenAt = now
+		ok, err := s.cache.HashSetIfExists(ctx, sessionKeyPrefix+id, map[string]string{
+			sessionLastSeenField: now.Format(time.RFC3339Nano),
+		}, time.Until(s.deadline(session)))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrSessionNotFound
+		}
+	}
+
+	return session, nil
+}
+
+// Get returns a live session by its public ID.
+func (s *store) Get(ctx context.Context, id string) (*Session, error) {
+	if id == "" {
+		return nil, ErrSessionNotFound
+	}
+
+	fields, err := s.cache.HashGetAll(ctx, sessionKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := decodeSession(fields)
+	if !ok || s.expired(session, time.Now()) {
+		return nil, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// ListByUser returns the user's live sessions, most recently used first.
+// Sessions that have expired are pruned from the index on the way.
+func (s *store) ListByUser(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	key := userSessionsKeyPrefix + userID.String()
+	ids, err := s.cache.SetMembers(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(ids))
+	var ended []string
+	for _, id := range ids {
+		session, err := s.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrSessionNotFound) {
+				ended = append(ended, id)
+				continue
+			}
+			return nil, err
+		}
+		if session.UserID != userID {
+			ended = append(ended, id)
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err := s.cache.SetRemove(ctx, key, ended...); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+// SessionIDsByUser returns the IDs of the user's live sessions.
+func (s *store) SessionIDsByUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	sessions, err := s.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	return ids, nil
+}
+
+// Reauthenticate records a new authentication of the session's user in
+// place, so the session keeps its ID, cookie and participating clients.
+func (s *store) Reauthenticate(ctx context.Context, id string, params ReauthenticateParams) (*Session, error) {
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	session.AuthTime = params.AuthTime.UTC()
+	session.AMR = params.AMR
+	session.ACR = params.ACR
+
+	fields, err := encodeSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.cache.HashSetIfExists(ctx, sessionKeyPrefix+id, map[string]string{
+		sessionDataField: fields[sessionDataField],
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// AddClient records that clientID received tokens in the session so it can be
+// notified on logout. A session that has already ended is ignored.
+func (s *store) AddClient(ctx context.Context, id, clientID string) error {
+	if id == "" {
+		return nil
+	}
+
+	_, err := s.cache.HashSetIfExists(ctx, sessionKeyPrefix+id, map[string]string{
+		sessionClientFieldPrefix + clientID: "1",
+	}, 0)
+	return err
+}
+
+// Terminate deletes the session and returns the clients that took part in it.
+func (s *store) Terminate(ctx context.Context, id string) ([]string, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	fields, err := s.cache.HashGetAllAndDelete(ctx, sessionKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := decodeSession(fields)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := s.cache.SetRemove(ctx, userSessionsKeyPrefix+session.UserID.String(), id); err != nil {
+		return nil, err
+	}
+
+	return session.Clients, nil
+}
+
+func (s *store) deadline(session *Session) time.Time {
+	idle := session.LastSeenAt.Add(s.cfg.SessionIdleTimeout)
+	if idle.Before(session.ExpiresAt) {
+		return idle
+	}
+	return session.ExpiresAt
+}
+
+func (s *store) expired(session *Session, now time.Time) bool {
+	return !now.Before(s.deadline(session))
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func encodeSession(session *Session) (map[string]string, error) {
+	data, err := json.Marshal(sessionData{
+		ID:         session.ID,
+		SecretHash: session.SecretHash,
+		UserID:     session.UserID,
+		AuthTime:   session.AuthTime,
+		AMR:        session.AMR,
+		ACR:        session.ACR,
+		IPAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		ExpiresAt:  session.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{
+		sessionDataField:     string(data),
+		sessionLastSeenField: session.LastSeenAt.Format(time.RFC3339Nano),
+	}
+	for _, clientID := range session.Clients {
+		fields[sessionClientFieldPrefix+clientID] = "1"
+	}
+
+	return fields, nil
+}
+
+// decodeSession rebuilds a Session from its hash fields. It reports false
+// for a missing or corrupt hash.
+func decodeSession(fields map[string]string) (*Session, bool) {
+	var data sessionData
+	if err := json.Unmarshal([]byte(fields[sessionDataField]), &data); err != nil || data.ID == "" {
+		return nil, false
+	}
+
+	session := &Session{
+		ID:         data.ID,
+		SecretHash: data.SecretHash,
+		UserID:     data.UserID,
+		AuthTime:   data.AuthTime,
+		AMR:        data.AMR,
+		ACR:        data.ACR,
+		IPAddress:  data.IPAddress,
+		UserAgent:  data.UserAgent,
+		CreatedAt:  data.CreatedAt,
+		LastSeenAt: data.CreatedAt,
+		ExpiresAt:  data.ExpiresAt,
+	}
+	if lastSeen, err := time.Parse(time.RFC3339Nano, fields[sessionLastSeenField]); err == nil {
+		session.LastSeenAt = lastSeen
+	}
+
+	for field := range fields {
+		if clientID, ok := strings.CutPrefix(field, sessionClientFieldPrefix); ok {
+			session.Clients = append(session.Clients, clientID)
+		}
+	}
+	sort.Strings(session.Clients)
+
+	return session, true
+}
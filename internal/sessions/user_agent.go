@@ -0,0 +1,53 @@
+package sessions
+
+import "strings"
+
+// Device is a coarse, human-readable description of the user agent that
+// created a session, good enough for a session dashboard.
+type Device struct {
+	Browser string `json:"browser"`
+	OS      string `json:"os"`
+	Mobile  bool   `json:"mobile"`
+}
+
+// DescribeUserAgent guesses the browser and operating system from a
+// User-Agent header. Unknown values are reported as "Unknown".
+func DescribeUserAgent(userAgent string) Device {
+	ua := strings.ToLower(userAgent)
+
+	return Device{
+		Browser: firstMatch(ua, []uaPattern{
+			{"edg/", "Edge"},
+			{"opr/", "Opera"},
+			{"firefox/", "Firefox"},
+			{"chrome/", "Chrome"},
+			{"safari/", "Safari"},
+			{"curl/", "curl"},
+		}),
+		OS: firstMatch(ua, []uaPattern{
+			{"android", "Android"},
+			{"iphone", "iOS"},
+			{"ipad", "iPadOS"},
+			{"windows", "Windows"},
+			{"mac os x", "macOS"},
+			{"linux", "Linux"},
+		}),
+		Mobile: strings.Contains(ua, "mobile"),
+	}
+}
+
+// uaPattern maps a lowercase User-Agent token to a display name. Order
+// matters: Chromium-based browsers also advertise "chrome/" and "safari/".
+type uaPattern struct {
+	token string
+	name  string
+}
+
+func firstMatch(ua string, patterns []uaPattern) string {
+	for _, pattern := range patterns {
+		if strings.Contains(ua, pattern.token) {
+			return pattern.name
+		}
+	}
+	return "Unknown"
+}
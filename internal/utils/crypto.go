@@ -2,8 +2,13 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -14,8 +19,30 @@ const (
 	argonThreads uint8  = 2
 	argonKeyLen  uint32 = 32
 	argonSaltLen uint32 = 16
+
+	// Ceilings for parameters read back from stored hashes. A corrupted or
+	// imported hash beyond them is rejected instead of making the next
+	// verification allocate or spin without bound.
+	argonMaxMemory  uint32 = 256 * 1024
+	argonMaxTime    uint32 = 16
+	argonMaxThreads uint8  = 16
+	argonMaxKeyLen         = 128
+)
+
+var (
+	ErrInvalidHash              = errors.New("invalid hash encoding")
+	ErrUnsupportedHashAlgorithm = errors.New("unsupported hash algorithm")
+	ErrIncompatibleHashVersion  = errors.New("incompatible argon2 version")
 )
 
+type argonParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
 func HashSensitiveValue(value string) (string, error) {
 	salt := make([]byte, argonSaltLen)
 	if _, err := rand.Read(salt); err != nil {
@@ -31,6 +58,78 @@ func HashSensitiveValue(value string) (string, error) {
 	return encoded, nil
 }
 
+// VerifySensitiveValue checks value against a hash produced by
+// HashSensitiveValue using the parameters recorded in the encoding. needsRehash
+// is true when the hash matches but was created with different parameters than
+// the ones currently in use.
+func VerifySensitiveValue(value, encoded string) (match bool, needsRehash bool, err error) {
+	params, err := decodeArgonHash(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	candidate := argon2.IDKey([]byte(value), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	if subtle.ConstantTimeCompare(candidate, params.key) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash = params.memory != argonMemory ||
+		params.time != argonTime ||
+		params.threads != argonThreads ||
+		uint32(len(params.key)) != argonKeyLen ||
+		uint32(len(params.salt)) != argonSaltLen
+
+	return true, needsRehash, nil
+}
+
+func decodeArgonHash(encoded string) (*argonParams, error) {
+	parts := strings.Split(strings.TrimPrefix(encoded, "$"), "$")
+	if len(parts) != 5 {
+		return nil, ErrInvalidHash
+	}
+
+	if parts[0] != "argon2id" {
+		return nil, ErrUnsupportedHashAlgorithm
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[1], "v=%d", &version); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return nil, ErrIncompatibleHashVersion
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if memory == 0 || time == 0 || threads == 0 ||
+		memory > argonMaxMemory || time > argonMaxTime || threads > argonMaxThreads {
+		return nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(salt) == 0 {
+		return nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(key) == 0 || len(key) > argonMaxKeyLen {
+		return nil, ErrInvalidHash
+	}
+
+	return &argonParams{memory: memory, time: time, threads: threads, salt: salt, key: key}, nil
+}
+
+// HashToken returns the hex SHA-256 digest of a high-entropy token. Cache
+// keys use it so a dump of the cache holds nothing redeemable.
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
 func GenerateRandomToken(byteLen int) (string, error) {
 	buf := make([]byte, byteLen)
 	if _, err := rand.Read(buf); err != nil {
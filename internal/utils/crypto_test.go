@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// encodeArgonHash builds an encoded hash of value with explicit parameters,
+// the way an older release or an import would have stored it.
+func encodeArgonHash(value string, memory, time uint32, threads uint8, salt []byte, keyLen uint32) string {
+	key := argon2.IDKey([]byte(value), salt, time, memory, threads, keyLen)
+	return fmt.Sprintf("argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestVerifySensitiveValue(t *testing.T) {
+	current, err := HashSensitiveValue("correct horse")
+	if err != nil {
+		t.Fatalf("HashSensitiveValue: %v", err)
+	}
+
+	salt := []byte("0123456789abcdef")
+	weaker := encodeArgonHash("correct horse", 8*1024, 1, 1, salt, argonKeyLen)
+
+	tests := []struct {
+		name        string
+		value       string
+		encoded     string
+		match       bool
+		needsRehash bool
+	}{
+		{name: "current parameters", value: "correct horse", encoded: current, match: true},
+		{name: "wrong value", value: "battery staple", encoded: current},
+		{name: "leading dollar", value: "correct horse", encoded: "$" + current, match: true},
+		{name: "older parameters", value: "correct horse", encoded: weaker, match: true, needsRehash: true},
+		{name: "older parameters wrong value", value: "battery staple", encoded: weaker},
+		{name: "shorter key", value: "correct horse", encoded: encodeArgonHash("correct horse", argonMemory, argonTime, argonThreads, salt, 16), match: true, needsRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash, err := VerifySensitiveValue(tt.value, tt.encoded)
+			if err != nil {
+				t.Fatalf("VerifySensitiveValue: %v", err)
+			}
+			if match != tt.match || needsRehash != tt.needsRehash {
+				t.Fatalf("VerifySensitiveValue = (%t, %t), want (%t, %t)", match, needsRehash, tt.match, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestVerifySensitiveValueRejectsMalformedHashes(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	encode := func(algorithm, version, params, salt, key string) string {
+		return algorithm + "$" + version + "$" + params + "$" + salt + "$" + key
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    error
+	}{
+		{name: "empty", encoded: "", want: ErrInvalidHash},
+		{name: "missing segment", encoded: "argon2id$v=19$m=65536,t=3,p=2$" + salt, want: ErrInvalidHash},
+		{name: "argon2i", encoded: encode("argon2i", "v=19", "m=65536,t=3,p=2", salt, key), want: ErrUnsupportedHashAlgorithm},
+		{name: "bcrypt", encoded: encode("2b", "v=19", "m=65536,t=3,p=2", salt, key), want: ErrUnsupportedHashAlgorithm},
+		{name: "old version", encoded: encode("argon2id", "v=16", "m=65536,t=3,p=2", salt, key), want: ErrIncompatibleHashVersion},
+		{name: "unparsable version", encoded: encode("argon2id", "version", "m=65536,t=3,p=2", salt, key), want: ErrInvalidHash},
+		{name: "unparsable parameters", encoded: encode("argon2id", "v=19", "memory=65536", salt, key), want: ErrInvalidHash},
+		{name: "zero memory", encoded: encode("argon2id", "v=19", "m=0,t=3,p=2", salt, key), want: ErrInvalidHash},
+		{name: "zero time", encoded: encode("argon2id", "v=19", "m=65536,t=0,p=2", salt, key), want: ErrInvalidHash},
+		{name: "zero threads", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=0", salt, key), want: ErrInvalidHash},
+		{name: "huge memory", encoded: encode("argon2id", "v=19", "m=4294967295,t=3,p=2", salt, key), want: ErrInvalidHash},
+		{name: "huge time", encoded: encode("argon2id", "v=19", "m=65536,t=1000000,p=2", salt, key), want: ErrInvalidHash},
+		{name: "huge threads", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=255", salt, key), want: ErrInvalidHash},
+		{name: "bad salt base64", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=2", "not*base64", key), want: ErrInvalidHash},
+		{name: "empty salt", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=2", "", key), want: ErrInvalidHash},
+		{name: "bad key base64", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=2", salt, "not*base64"), want: ErrInvalidHash},
+		{name: "empty key", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=2", salt, ""), want: ErrInvalidHash},
+		{name: "huge key", encoded: encode("argon2id", "v=19", "m=65536,t=3,p=2", salt, base64.RawStdEncoding.EncodeToString(make([]byte, 4096))), want: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash, err := VerifySensitiveValue("value", tt.encoded)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("VerifySensitiveValue error = %v, want %v", err, tt.want)
+			}
+			if match || needsRehash {
+				t.Fatalf("VerifySensitiveValue = (%t, %t) for a malformed hash", match, needsRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrInvalidEncryptionKey = errors.New("encryption key must be 32 bytes")
+	ErrInvalidCiphertext    = errors.New("invalid ciphertext")
+)
+
+// ParseEncryptionKey decodes a base64 (standard or URL alphabet) AES-256 key.
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		key, err := encoding.DecodeString(encoded)
+		if err == nil && len(key) == 32 {
+			return key, nil
+		}
+	}
+	return nil, ErrInvalidEncryptionKey
+}
+
+// EncryptValue seals plaintext with AES-256-GCM and returns
+// base64(nonce || ciphertext).
+func EncryptValue(key []byte, plaintext string) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue reverses EncryptValue.
+func DecryptValue(key []byte, encoded string) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidEncryptionKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,104 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Authenticator data flags (WebAuthn §6.1).
+const (
+	flagUserPresent    byte = 0x01
+	flagUserVerified   byte = 0x04
+	flagBackupEligible byte = 0x08
+	flagBackupState    byte = 0x10
+	flagAttestedData   byte = 0x40
+	flagExtensionData  byte = 0x80
+
+	rpIDHashLength = 32
+	aaguidLength   = 16
+	maxCredIDLen   = 1023
+)
+
+// authenticatorData is the parsed form of the authenticator data structure
+// signed by the authenticator.
+type authenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// Attested credential data, present during registration only.
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (d *authenticatorData) UserPresent() bool    { return d.Flags&flagUserPresent != 0 }
+func (d *authenticatorData) UserVerified() bool   { return d.Flags&flagUserVerified != 0 }
+func (d *authenticatorData) BackupEligible() bool { return d.Flags&flagBackupEligible != 0 }
+func (d *authenticatorData) BackupState() bool    { return d.Flags&flagBackupState != 0 }
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < rpIDHashLength+5 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+
+	parsed := &authenticatorData{
+		RPIDHash:  data[:rpIDHashLength],
+		Flags:     data[rpIDHashLength],
+		SignCount: binary.BigEndian.Uint32(data[rpIDHashLength+1:]),
+	}
+	rest := data[rpIDHashLength+5:]
+
+	// A backed-up credential must be backup eligible.
+	if parsed.BackupState() && !parsed.BackupEligible() {
+		return nil, fmt.Errorf("%w: invalid backup flags", ErrInvalidResponse)
+	}
+
+	if parsed.Flags&flagAttestedData != 0 {
+		if len(rest) < aaguidLength+2 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		parsed.AAGUID = rest[:aaguidLength]
+		idLength := int(binary.BigEndian.Uint16(rest[aaguidLength:]))
+		rest = rest[aaguidLength+2:]
+		if idLength == 0 || idLength > maxCredIDLen || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential id length", ErrInvalidResponse)
+		}
+		parsed.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, remaining, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+		}
+		parsed.PublicKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if parsed.Flags&flagExtensionData != 0 {
+		// Extension outputs are not used, but must be well formed.
+		_, remaining, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: extensions: %v", ErrInvalidResponse, err)
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidResponse)
+	}
+
+	return parsed, nil
+}
+
+func encodeAuthenticatorData(rpIDHash []byte, flags byte, signCount uint32, aaguid, credentialID, publicKey []byte) []byte {
+	data := append([]byte(nil), rpIDHash...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	if flags&flagAttestedData != 0 {
+		data = append(data, aaguid...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(credentialID)))
+		data = append(data, credentialID...)
+		data = append(data, publicKey...)
+	}
+	return data
+}
@@ -0,0 +1,254 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// This file implements the subset of CBOR (RFC 8949) that WebAuthn needs:
+// attestation objects and COSE keys only use integers, byte and text
+// strings, arrays, maps, booleans and null.
+
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+
+	cborMaxDepth = 16
+)
+
+var ErrInvalidCBOR = errors.New("invalid cbor")
+
+// decodeCBOR decodes one item from data and returns it with the remaining
+// bytes. Integers decode to int64, maps to map[any]any keyed by int64 or
+// string.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: nesting too deep", ErrInvalidCBOR)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidCBOR)
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		default:
+			return nil, nil, fmt.Errorf("%w: unsupported simple value %d", ErrInvalidCBOR, info)
+		}
+	}
+
+	arg, data, err := readCBORArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return int64(arg), data, nil
+	case cborNegative:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrInvalidCBOR)
+		}
+		return -1 - int64(arg), data, nil
+	case cborBytes, cborText:
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: string exceeds data", ErrInvalidCBOR)
+		}
+		value := data[:arg]
+		if major == cborText {
+			return string(value), data[arg:], nil
+		}
+		return append([]byte(nil), value...), data[arg:], nil
+	case cborArray:
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: array exceeds data", ErrInvalidCBOR)
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item any
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case cborMap:
+		if arg > uint64(len(data)) {
+			return nil, nil, fmt.Errorf("%w: map exceeds data", ErrInvalidCBOR)
+		}
+		items := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value any
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key", ErrInvalidCBOR)
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, data, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported major type %d", ErrInvalidCBOR, major)
+	}
+}
+
+func readCBORArgument(info byte, data []byte) (uint64, []byte, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// Indefinite lengths are not used by authenticators.
+		return 0, nil, fmt.Errorf("%w: unsupported length encoding", ErrInvalidCBOR)
+	}
+
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidCBOR)
+	}
+
+	var arg uint64
+	switch size {
+	case 1:
+		arg = uint64(data[0])
+	case 2:
+		arg = uint64(binary.BigEndian.Uint16(data))
+	case 4:
+		arg = uint64(binary.BigEndian.Uint32(data))
+	case 8:
+		arg = binary.BigEndian.Uint64(data)
+	}
+
+	return arg, data[size:], nil
+}
+
+// encodeCBOR encodes value using the canonical CTAP2 form (shortest
+// lengths, map keys sorted). It is used by the software authenticator.
+func encodeCBOR(value any) ([]byte, error) {
+	var out []byte
+	if err := appendCBOR(&out, value); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func appendCBOR(out *[]byte, value any) error {
+	switch v := value.(type) {
+	case nil:
+		*out = append(*out, 0xf6)
+	case bool:
+		if v {
+			*out = append(*out, 0xf5)
+		} else {
+			*out = append(*out, 0xf4)
+		}
+	case int:
+		appendCBORInt(out, int64(v))
+	case int64:
+		appendCBORInt(out, v)
+	case []byte:
+		appendCBORHeader(out, cborBytes, uint64(len(v)))
+		*out = append(*out, v...)
+	case string:
+		appendCBORHeader(out, cborText, uint64(len(v)))
+		*out = append(*out, v...)
+	case []any:
+		appendCBORHeader(out, cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := appendCBOR(out, item); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		type entry struct {
+			key   []byte
+			value any
+		}
+		entries := make([]entry, 0, len(v))
+		for key, item := range v {
+			encoded, err := encodeCBOR(key)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{key: encoded, value: item})
+		}
+		// CTAP2 canonical ordering: shorter keys first, then bytewise.
+		sort.Slice(entries, func(i, j int) bool {
+			if len(entries[i].key) != len(entries[j].key) {
+				return len(entries[i].key) < len(entries[j].key)
+			}
+			return string(entries[i].key) < string(entries[j].key)
+		})
+		appendCBORHeader(out, cborMap, uint64(len(entries)))
+		for _, e := range entries {
+			*out = append(*out, e.key...)
+			if err := appendCBOR(out, e.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: cannot encode %T", ErrInvalidCBOR, value)
+	}
+	return nil
+}
+
+func appendCBORInt(out *[]byte, v int64) {
+	if v >= 0 {
+		appendCBORHeader(out, cborUnsigned, uint64(v))
+		return
+	}
+	appendCBORHeader(out, cborNegative, uint64(-1-v))
+}
+
+func appendCBORHeader(out *[]byte, major byte, arg uint64) {
+	head := major << 5
+	switch {
+	case arg < 24:
+		*out = append(*out, head|byte(arg))
+	case arg <= math.MaxUint8:
+		*out = append(*out, head|24, byte(arg))
+	case arg <= math.MaxUint16:
+		*out = binary.BigEndian.AppendUint16(append(*out, head|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		*out = binary.BigEndian.AppendUint32(append(*out, head|26), uint32(arg))
+	default:
+		*out = binary.BigEndian.AppendUint64(append(*out, head|27), arg)
+	}
+}
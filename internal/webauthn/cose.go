@@ -0,0 +1,149 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for credentials.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms is advertised in pubKeyCredParams, most preferred
+// first.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+
+	// Curve, x and y for EC2/OKP keys; n and e reuse -1 and -2 for RSA.
+	coseParamCurve = -1
+	coseParamX     = -2
+	coseParamY     = -3
+	coseParamN     = -1
+	coseParamE     = -2
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported cose key")
+	ErrBadSignature   = errors.New("signature verification failed")
+)
+
+// PublicKey is a credential public key decoded from its COSE form.
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as stored with a credential.
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	value, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidCBOR)
+	}
+
+	params, ok := value.(map[any]any)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	keyType, _ := params[int64(coseKeyType)].(int64)
+	alg, _ := params[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && alg == AlgES256:
+		curve, _ := params[int64(coseParamCurve)].(int64)
+		x, _ := params[int64(coseParamX)].([]byte)
+		y, _ := params[int64(coseParamY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		// Reject points off the curve before they reach ecdsa.Verify.
+		encoded := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(encoded); err != nil {
+			return nil, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &PublicKey{Algorithm: alg, Key: key}, nil
+	case keyType == coseKeyTypeOKP && alg == AlgEdDSA:
+		curve, _ := params[int64(coseParamCurve)].(int64)
+		x, _ := params[int64(coseParamX)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case keyType == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := params[int64(coseParamN)].([]byte)
+		e, _ := params[int64(coseParamE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		exponent := new(big.Int).SetBytes(e)
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		return &PublicKey{Algorithm: alg, Key: key}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// Verify checks sig over data with the algorithm the key was registered
+// with.
+func (k *PublicKey) Verify(data, sig []byte) error {
+	switch key := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return ErrBadSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, sig) {
+			return ErrBadSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return ErrBadSignature
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+	return nil
+}
+
+// encodeES256PublicKey returns the COSE_Key for a P-256 public key.
+func encodeES256PublicKey(key *ecdsa.PublicKey) ([]byte, error) {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	return encodeCBOR(map[any]any{
+		int64(coseKeyType):    int64(coseKeyTypeEC2),
+		int64(coseAlgorithm):  AlgES256,
+		int64(coseParamCurve): int64(coseCurveP256),
+		int64(coseParamX):     x,
+		int64(coseParamY):     y,
+	})
+}
@@ -0,0 +1,180 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrNoCredential = errors.New("authenticator has no matching credential")
+
+// SoftwareAuthenticator is an in-memory ES256 authenticator. It produces the
+// same JSON a browser would, so the ceremonies can be exercised from Go
+// without one.
+type SoftwareAuthenticator struct {
+	// Origin is reported in client data; it must match the relying party.
+	Origin string
+	// UserVerified and BackupEligible control the flags set on every
+	// response.
+	UserVerified   bool
+	BackupEligible bool
+	// DisableSignCount makes the authenticator always report zero, like
+	// most passkey providers.
+	DisableSignCount bool
+
+	credentials []*softwareCredential
+}
+
+type softwareCredential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+func NewSoftwareAuthenticator(origin string) *SoftwareAuthenticator {
+	return &SoftwareAuthenticator{Origin: origin, UserVerified: true}
+}
+
+// Register creates a discoverable credential for options, as
+// navigator.credentials.create() would.
+func (a *SoftwareAuthenticator) Register(options CreationOptions) (*RegistrationResponse, error) {
+	userHandle, err := DecodeBase64URL(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := encodeES256PublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &softwareCredential{id: id, rpID: options.RP.ID, userHandle: userHandle, key: key}
+	a.credentials = append(a.credentials, credential)
+
+	rpIDHash := sha256.Sum256([]byte(options.RP.ID))
+	authData := encodeAuthenticatorData(rpIDHash[:], a.flags()|flagAttestedData, a.nextSignCount(credential), make([]byte, aaguidLength), id, publicKey)
+
+	attestation, err := encodeCBOR(map[any]any{
+		"fmt":      attestationNone,
+		"attStmt":  map[any]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataJSON, err := a.clientData(clientDataTypeCreate, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RegistrationResponse{ID: EncodeBase64URL(id), RawID: EncodeBase64URL(id), Type: publicKeyCredentialType}
+	response.Response.ClientDataJSON = EncodeBase64URL(clientDataJSON)
+	response.Response.AttestationObject = EncodeBase64URL(attestation)
+	response.Response.Transports = []string{"internal"}
+
+	return response, nil
+}
+
+// Assert signs options with the first credential allowed by them, or with
+// any credential for the RP when AllowCredentials is empty.
+func (a *SoftwareAuthenticator) Assert(options RequestOptions) (*AssertionResponse, error) {
+	credential, err := a.find(options)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(credential.rpID))
+	authData := encodeAuthenticatorData(rpIDHash[:], a.flags(), a.nextSignCount(credential), nil, nil, nil)
+
+	clientDataJSON, err := a.clientData(clientDataTypeGet, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, credential.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	response := &AssertionResponse{ID: EncodeBase64URL(credential.id), RawID: EncodeBase64URL(credential.id), Type: publicKeyCredentialType}
+	response.Response.ClientDataJSON = EncodeBase64URL(clientDataJSON)
+	response.Response.AuthenticatorData = EncodeBase64URL(authData)
+	response.Response.Signature = EncodeBase64URL(signature)
+	response.Response.UserHandle = EncodeBase64URL(credential.userHandle)
+
+	return response, nil
+}
+
+// SetSignCount overwrites a credential's counter, e.g. to simulate a cloned
+// authenticator.
+func (a *SoftwareAuthenticator) SetSignCount(credentialID []byte, count uint32) error {
+	for _, credential := range a.credentials {
+		if string(credential.id) == string(credentialID) {
+			credential.signCount = count
+			return nil
+		}
+	}
+	return ErrNoCredential
+}
+
+func (a *SoftwareAuthenticator) find(options RequestOptions) (*softwareCredential, error) {
+	for _, credential := range a.credentials {
+		if credential.rpID != options.RPID {
+			continue
+		}
+		if len(options.AllowCredentials) == 0 {
+			return credential, nil
+		}
+		for _, allowed := range options.AllowCredentials {
+			if allowed.ID == EncodeBase64URL(credential.id) {
+				return credential, nil
+			}
+		}
+	}
+	return nil, ErrNoCredential
+}
+
+func (a *SoftwareAuthenticator) flags() byte {
+	flags := flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	if a.BackupEligible {
+		flags |= flagBackupEligible | flagBackupState
+	}
+	return flags
+}
+
+func (a *SoftwareAuthenticator) nextSignCount(credential *softwareCredential) uint32 {
+	if a.DisableSignCount {
+		return 0
+	}
+	credential.signCount++
+	return credential.signCount
+}
+
+func (a *SoftwareAuthenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	if challenge == "" {
+		return nil, fmt.Errorf("%w: empty challenge", ErrInvalidResponse)
+	}
+	return json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: a.Origin})
+}
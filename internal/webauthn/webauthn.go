@@ -0,0 +1,477 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies (W3C Web Authentication
+// Level 3) using only the standard library.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	challengeLength = 32
+
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+
+	publicKeyCredentialType = "public-key"
+
+	// attestationNone is the only attestation conveyance requested; the
+	// attestation statement is therefore never trusted.
+	attestationNone = "none"
+
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+)
+
+var (
+	ErrInvalidRelyingParty = errors.New("invalid relying party")
+	ErrInvalidResponse     = errors.New("invalid webauthn response")
+	ErrChallengeMismatch   = errors.New("webauthn challenge mismatch")
+	ErrOriginMismatch      = errors.New("webauthn origin mismatch")
+	ErrUserNotPresent      = errors.New("user presence was not asserted")
+	ErrUserNotVerified     = errors.New("user verification was required")
+	// ErrSignCountRegression means the authenticator reported a counter not
+	// greater than the stored one, a sign that the credential was cloned.
+	ErrSignCountRegression = errors.New("signature counter did not increase")
+)
+
+// RelyingParty identifies this server to authenticators. ID is the
+// registrable domain credentials are scoped to and Origin the exact origin
+// the browser must report.
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// NewRelyingParty derives the relying party from the issuer URL: the RP ID
+// is its host name and the origin its scheme and host.
+func NewRelyingParty(issuerURL, name string) (*RelyingParty, error) {
+	parsed, err := url.Parse(issuerURL)
+	if err != nil || parsed.Hostname() == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return nil, fmt.Errorf("%w: issuer url %q", ErrInvalidRelyingParty, issuerURL)
+	}
+
+	return &RelyingParty{
+		ID:     parsed.Hostname(),
+		Name:   name,
+		Origin: parsed.Scheme + "://" + parsed.Host,
+	}, nil
+}
+
+// User is the account a credential is created for. ID is the user handle;
+// it must not contain personal information.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	RequireResident  bool   `json:"requireResidentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CredentialDescriptor references an existing credential in options.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions,
+// ready for PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     rpEntity               `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions. An
+// empty AllowCredentials asks for a discoverable credential.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// NewChallenge returns a fresh random ceremony challenge.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// NewCredentialDescriptor builds a descriptor for allow/exclude lists.
+func NewCredentialDescriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: publicKeyCredentialType, ID: EncodeBase64URL(id), Transports: transports}
+}
+
+// CreationOptions builds registration options. Discoverable credentials are
+// preferred so the credential can later be used as a passkey.
+func (rp *RelyingParty) CreationOptions(user User, challenge []byte, exclude []CredentialDescriptor, timeout time.Duration) CreationOptions {
+	params := make([]credentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, credentialParameter{Type: publicKeyCredentialType, Alg: alg})
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+
+	return CreationOptions{
+		Challenge:          EncodeBase64URL(challenge),
+		RP:                 rpEntity{ID: rp.ID, Name: rp.Name},
+		User:               userEntity{ID: EncodeBase64URL(user.ID), Name: user.Name, DisplayName: user.DisplayName},
+		PubKeyCredParams:   params,
+		Timeout:            timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: UserVerificationPreferred,
+		},
+		Attestation: attestationNone,
+	}
+}
+
+// RequestOptions builds authentication options.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor, userVerification string, timeout time.Duration) RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+
+	return RequestOptions{
+		Challenge:        EncodeBase64URL(challenge),
+		RPID:             rp.ID,
+		Timeout:          timeout.Milliseconds(),
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// RegistrationResponse is the JSON form of a PublicKeyCredential returned by
+// navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of a PublicKeyCredential returned by
+// navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Challenge returns the challenge the browser signed, so the caller can
+// look up the ceremony it belongs to. It is not verified yet.
+func (r *RegistrationResponse) Challenge() ([]byte, error) {
+	return responseChallenge(r.Response.ClientDataJSON)
+}
+
+// Challenge returns the challenge the browser signed, unverified.
+func (r *AssertionResponse) Challenge() ([]byte, error) {
+	return responseChallenge(r.Response.ClientDataJSON)
+}
+
+// CredentialID decodes the credential ID the assertion was made with.
+func (r *AssertionResponse) CredentialID() ([]byte, error) {
+	return credentialID(r.ID, r.RawID, r.Type)
+}
+
+// UserHandle decodes the user handle returned by discoverable credentials.
+// It is empty for non-discoverable ones.
+func (r *AssertionResponse) UserHandle() ([]byte, error) {
+	return DecodeBase64URL(r.Response.UserHandle)
+}
+
+// Credential is a verified new credential to be stored with the user.
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool
+	BackupState    bool
+}
+
+// Assertion is the verified result of an authentication ceremony.
+type Assertion struct {
+	SignCount      uint32
+	UserVerified   bool
+	BackupEligible bool
+	BackupState    bool
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// VerifyRegistration runs the registration ceremony checks (WebAuthn §7.1)
+// against the challenge issued for it.
+func (rp *RelyingParty) VerifyRegistration(response *RegistrationResponse, challenge []byte, userVerification string) (*Credential, error) {
+	id, err := credentialID(response.ID, response.RawID, response.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := rp.verifyClientData(response.Response.ClientDataJSON, clientDataTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestation, err := DecodeBase64URL(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object encoding", ErrInvalidResponse)
+	}
+	value, rest, err := decodeCBOR(rawAttestation)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: attestation object", ErrInvalidResponse)
+	}
+	attestation, ok := value.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object", ErrInvalidResponse)
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing authenticator data", ErrInvalidResponse)
+	}
+	// Attestation is requested as "none", so whatever statement an
+	// authenticator still sends is not verified or trusted (§7.1 step 21
+	// leaves this to RP policy).
+	if _, ok := attestation["fmt"].(string); !ok {
+		return nil, fmt.Errorf("%w: missing attestation format", ErrInvalidResponse)
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData, userVerification)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, fmt.Errorf("%w: missing attested credential data", ErrInvalidResponse)
+	}
+	if !bytes.Equal(authData.CredentialID, id) {
+		return nil, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+
+	publicKey, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             id,
+		PublicKey:      append([]byte(nil), authData.PublicKey...),
+		Algorithm:      publicKey.Algorithm,
+		SignCount:      authData.SignCount,
+		AAGUID:         append([]byte(nil), authData.AAGUID...),
+		Transports:     response.Response.Transports,
+		UserVerified:   authData.UserVerified(),
+		BackupEligible: authData.BackupEligible(),
+		BackupState:    authData.BackupState(),
+	}, nil
+}
+
+// VerifyAssertion runs the authentication ceremony checks (WebAuthn §7.2)
+// for a credential whose COSE public key and last sign count are stored.
+func (rp *RelyingParty) VerifyAssertion(response *AssertionResponse, challenge, publicKey []byte, storedSignCount uint32, userVerification string) (*Assertion, error) {
+	rawClientData, err := rp.verifyClientData(response.Response.ClientDataJSON, clientDataTypeGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := DecodeBase64URL(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: authenticator data encoding", ErrInvalidResponse)
+	}
+	authData, err := rp.verifyAuthenticatorData(rawAuthData, userVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := DecodeBase64URL(response.Response.Signature)
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("%w: signature encoding", ErrInvalidResponse)
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	if err := key.Verify(append(append([]byte(nil), rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return nil, err
+	}
+
+	if err := CheckSignCount(storedSignCount, authData.SignCount); err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		SignCount:      authData.SignCount,
+		UserVerified:   authData.UserVerified(),
+		BackupEligible: authData.BackupEligible(),
+		BackupState:    authData.BackupState(),
+	}, nil
+}
+
+// CheckSignCount applies the signature counter rule: authenticators that
+// keep a counter must report a larger value each time. Both values zero
+// means the authenticator does not implement a counter (common for
+// passkeys).
+func CheckSignCount(stored, received uint32) error {
+	if stored == 0 && received == 0 {
+		return nil
+	}
+	if received <= stored {
+		return ErrSignCountRegression
+	}
+	return nil
+}
+
+func (rp *RelyingParty) verifyClientData(encoded, expectedType string, challenge []byte) ([]byte, error) {
+	raw, err := DecodeBase64URL(encoded)
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("%w: client data encoding", ErrInvalidResponse)
+	}
+
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: client data", ErrInvalidResponse)
+	}
+	if data.Type != expectedType {
+		return nil, fmt.Errorf("%w: unexpected client data type %q", ErrInvalidResponse, data.Type)
+	}
+
+	received, err := DecodeBase64URL(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return nil, ErrChallengeMismatch
+	}
+	if data.Origin != rp.Origin || data.CrossOrigin {
+		return nil, ErrOriginMismatch
+	}
+
+	return raw, nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte, userVerification string) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rpIDHash[:]) != 1 {
+		return nil, fmt.Errorf("%w: rp id hash mismatch", ErrInvalidResponse)
+	}
+	if !authData.UserPresent() {
+		return nil, ErrUserNotPresent
+	}
+	if userVerification == UserVerificationRequired && !authData.UserVerified() {
+		return nil, ErrUserNotVerified
+	}
+
+	return authData, nil
+}
+
+func responseChallenge(encoded string) ([]byte, error) {
+	raw, err := DecodeBase64URL(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data encoding", ErrInvalidResponse)
+	}
+
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: client data", ErrInvalidResponse)
+	}
+
+	challenge, err := DecodeBase64URL(data.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, fmt.Errorf("%w: client data challenge", ErrInvalidResponse)
+	}
+	return challenge, nil
+}
+
+func credentialID(id, rawID, credentialType string) ([]byte, error) {
+	if credentialType != publicKeyCredentialType {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrInvalidResponse, credentialType)
+	}
+
+	decoded, err := DecodeBase64URL(id)
+	if err != nil || len(decoded) == 0 {
+		return nil, fmt.Errorf("%w: credential id encoding", ErrInvalidResponse)
+	}
+	if rawID != "" {
+		raw, err := DecodeBase64URL(rawID)
+		if err != nil || !bytes.Equal(raw, decoded) {
+			return nil, fmt.Errorf("%w: id and rawId differ", ErrInvalidResponse)
+		}
+	}
+
+	return decoded, nil
+}
+
+// EncodeBase64URL encodes binary values the way the WebAuthn JSON
+// serialization does: base64url without padding.
+func EncodeBase64URL(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// DecodeBase64URL accepts base64url with or without padding.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
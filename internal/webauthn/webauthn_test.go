@@ -0,0 +1,223 @@
+package webauthn
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://id.example.com"
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	t.Helper()
+
+	rp, err := NewRelyingParty(testIssuer, "Passport")
+	if err != nil {
+		t.Fatalf("NewRelyingParty: %v", err)
+	}
+	return rp
+}
+
+func newTestChallenge(t *testing.T) []byte {
+	t.Helper()
+
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	return challenge
+}
+
+// register runs a registration ceremony against rp and returns the stored
+// credential.
+func register(t *testing.T, rp *RelyingParty, authenticator *SoftwareAuthenticator) *Credential {
+	t.Helper()
+
+	challenge := newTestChallenge(t)
+	options := rp.CreationOptions(User{ID: []byte("user-1"), Name: "ada@example.com", DisplayName: "Ada"}, challenge, nil, time.Minute)
+
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	credential, err := rp.VerifyRegistration(response, challenge, UserVerificationRequired)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return credential
+}
+
+// assert runs an authentication ceremony for credential and returns the
+// verification result.
+func assert(t *testing.T, rp *RelyingParty, authenticator *SoftwareAuthenticator, credential *Credential, storedSignCount uint32, userVerification string) (*Assertion, error) {
+	t.Helper()
+
+	challenge := newTestChallenge(t)
+	allow := []CredentialDescriptor{NewCredentialDescriptor(credential.ID, nil)}
+	options := rp.RequestOptions(challenge, allow, userVerification, time.Minute)
+
+	response, err := authenticator.Assert(options)
+	if err != nil {
+		t.Fatalf("Assert: %v", err)
+	}
+
+	return rp.VerifyAssertion(response, challenge, credential.PublicKey, storedSignCount, userVerification)
+}
+
+func TestRegisterAndAssertRoundTrip(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator(rp.Origin)
+
+	credential := register(t, rp, authenticator)
+	if credential.Algorithm != AlgES256 {
+		t.Fatalf("algorithm = %d, want %d", credential.Algorithm, AlgES256)
+	}
+	if credential.SignCount != 1 {
+		t.Fatalf("registration sign count = %d, want 1", credential.SignCount)
+	}
+	if !credential.UserVerified {
+		t.Fatal("registration did not report user verification")
+	}
+
+	assertion, err := assert(t, rp, authenticator, credential, credential.SignCount, UserVerificationRequired)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if assertion.SignCount != 2 {
+		t.Fatalf("assertion sign count = %d, want 2", assertion.SignCount)
+	}
+	if !assertion.UserVerified {
+		t.Fatal("assertion did not report user verification")
+	}
+}
+
+func TestVerifyRegistrationRejectsOriginMismatch(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator("https://evil.example.com")
+
+	challenge := newTestChallenge(t)
+	response, err := authenticator.Register(rp.CreationOptions(User{ID: []byte("user-1"), Name: "ada@example.com"}, challenge, nil, time.Minute))
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if _, err := rp.VerifyRegistration(response, challenge, UserVerificationPreferred); !errors.Is(err, ErrOriginMismatch) {
+		t.Fatalf("VerifyRegistration error = %v, want %v", err, ErrOriginMismatch)
+	}
+}
+
+func TestVerifyAssertionRejectsOriginMismatch(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator(rp.Origin)
+	credential := register(t, rp, authenticator)
+
+	authenticator.Origin = "https://evil.example.com"
+
+	if _, err := assert(t, rp, authenticator, credential, credential.SignCount, UserVerificationPreferred); !errors.Is(err, ErrOriginMismatch) {
+		t.Fatalf("VerifyAssertion error = %v, want %v", err, ErrOriginMismatch)
+	}
+}
+
+func TestVerifyRegistrationRejectsRPIDHashMismatch(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator(rp.Origin)
+
+	challenge := newTestChallenge(t)
+	options := rp.CreationOptions(User{ID: []byte("user-1"), Name: "ada@example.com"}, challenge, nil, time.Minute)
+	options.RP.ID = "evil.example.com"
+
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if _, err := rp.VerifyRegistration(response, challenge, UserVerificationPreferred); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("VerifyRegistration error = %v, want %v", err, ErrInvalidResponse)
+	}
+}
+
+func TestVerifyAssertionRequiresUserVerification(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator(rp.Origin)
+	credential := register(t, rp, authenticator)
+
+	authenticator.UserVerified = false
+
+	if _, err := assert(t, rp, authenticator, credential, credential.SignCount, UserVerificationRequired); !errors.Is(err, ErrUserNotVerified) {
+		t.Fatalf("VerifyAssertion error = %v, want %v", err, ErrUserNotVerified)
+	}
+
+	assertion, err := assert(t, rp, authenticator, credential, credential.SignCount, UserVerificationPreferred)
+	if err != nil {
+		t.Fatalf("VerifyAssertion with preferred verification: %v", err)
+	}
+	if assertion.UserVerified {
+		t.Fatal("assertion reported user verification that did not happen")
+	}
+}
+
+func TestVerifyAssertionRejectsSignCountRegression(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator(rp.Origin)
+	credential := register(t, rp, authenticator)
+
+	assertion, err := assert(t, rp, authenticator, credential, credential.SignCount, UserVerificationRequired)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+
+	// A clone keeps signing from an older counter value.
+	if err := authenticator.SetSignCount(credential.ID, credential.SignCount); err != nil {
+		t.Fatalf("SetSignCount: %v", err)
+	}
+
+	if _, err := assert(t, rp, authenticator, credential, assertion.SignCount, UserVerificationRequired); !errors.Is(err, ErrSignCountRegression) {
+		t.Fatalf("VerifyAssertion error = %v, want %v", err, ErrSignCountRegression)
+	}
+}
+
+func TestVerifyAssertionAcceptsAuthenticatorWithoutCounter(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := NewSoftwareAuthenticator(rp.Origin)
+	authenticator.DisableSignCount = true
+	credential := register(t, rp, authenticator)
+
+	if credential.SignCount != 0 {
+		t.Fatalf("registration sign count = %d, want 0", credential.SignCount)
+	}
+
+	for i := 0; i < 2; i++ {
+		assertion, err := assert(t, rp, authenticator, credential, 0, UserVerificationRequired)
+		if err != nil {
+			t.Fatalf("VerifyAssertion %d: %v", i, err)
+		}
+		if assertion.SignCount != 0 {
+			t.Fatalf("assertion sign count = %d, want 0", assertion.SignCount)
+		}
+	}
+}
+
+func TestCheckSignCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   uint32
+		received uint32
+		want     error
+	}{
+		{name: "both zero", stored: 0, received: 0},
+		{name: "first use", stored: 0, received: 1},
+		{name: "increased", stored: 5, received: 6},
+		{name: "equal", stored: 5, received: 5, want: ErrSignCountRegression},
+		{name: "decreased", stored: 5, received: 4, want: ErrSignCountRegression},
+		{name: "reset to zero", stored: 5, received: 0, want: ErrSignCountRegression},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckSignCount(tt.stored, tt.received); !errors.Is(err, tt.want) {
+				t.Fatalf("CheckSignCount(%d, %d) = %v, want %v", tt.stored, tt.received, err, tt.want)
+			}
+		})
+	}
+}